@@ -0,0 +1,114 @@
+package httpreqx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Authenticator adds credentials to outgoing requests.
+// Authenticate is called for every attempt, after all headers are set and the before request hooks are executed.
+// HandleUnauthorized is called when a response with the 401 status code is received.
+// Returning true repeats the request once, with the same buffered body and a fresh call to Authenticate.
+// Implementations must be safe for concurrent use.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+	HandleUnauthorized(req *http.Request, resp *http.Response) bool
+}
+
+// APIKeyLocation defines where the API key is sent.
+type APIKeyLocation string
+
+const (
+	APIKeyInHeader APIKeyLocation = "header"
+	APIKeyInQuery  APIKeyLocation = "query"
+)
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+// NewBasicAuth creates an Authenticator that sends the credentials with HTTP Basic authentication.
+func NewBasicAuth(username, password string) Authenticator {
+	return &basicAuth{username: username, password: password}
+}
+
+func (a *basicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (a *basicAuth) HandleUnauthorized(*http.Request, *http.Response) bool {
+	return false
+}
+
+type bearerAuth struct {
+	token interface{}
+}
+
+// NewBearerToken creates an Authenticator that sends the "Authorization: Bearer <token>" header.
+// The token can be one of:
+// - string - a static token
+// - func() (string, error) - evaluated for every request
+// - func(ctx context.Context) (string, error) - evaluated for every request with the request context
+func NewBearerToken(tokenOrFunc interface{}) Authenticator {
+	return &bearerAuth{token: tokenOrFunc}
+}
+
+func (a *bearerAuth) Authenticate(req *http.Request) error {
+	var token string
+	var err error
+
+	switch v := a.token.(type) {
+	case string:
+		token = v
+	case func() (string, error):
+		token, err = v()
+	case func(ctx context.Context) (string, error):
+		token, err = v(req.Context())
+	default:
+		return fmt.Errorf("unsupported bearer token type: %T", a.token)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bearer token: %w", err)
+	}
+
+	req.Header.Set(HeaderAuthorization, "Bearer "+token)
+	return nil
+}
+
+func (a *bearerAuth) HandleUnauthorized(*http.Request, *http.Response) bool {
+	return false
+}
+
+type apiKeyAuth struct {
+	location APIKeyLocation
+	name     string
+	value    string
+}
+
+// NewAPIKey creates an Authenticator that sends the API key in the header or in the query parameter with the given name.
+func NewAPIKey(location APIKeyLocation, name, value string) Authenticator {
+	return &apiKeyAuth{location: location, name: name, value: value}
+}
+
+func (a *apiKeyAuth) Authenticate(req *http.Request) error {
+	switch a.location {
+	case APIKeyInHeader:
+		req.Header.Set(a.name, a.value)
+	case APIKeyInQuery:
+		query := req.URL.Query()
+		query.Set(a.name, a.value)
+		req.URL.RawQuery = query.Encode()
+	default:
+		return fmt.Errorf("unsupported API key location: %q", a.location)
+	}
+
+	return nil
+}
+
+func (a *apiKeyAuth) HandleUnauthorized(*http.Request, *http.Response) bool {
+	return false
+}
@@ -0,0 +1,222 @@
+package httpreqx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	r := require.New(t)
+
+	var tokensIssued int32
+	var revoked sync.Map
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		clientID, clientSecret, ok := req.BasicAuth()
+		if !ok || clientID != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := req.ParseForm(); err != nil || req.PostForm.Get("grant_type") != "client_credentials" || req.PostForm.Get("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Slow token endpoint, to make concurrent requests wait for the same refresh.
+		time.Sleep(20 * time.Millisecond)
+		n := atomic.AddInt32(&tokensIssued, 1)
+		w.Header().Set(HeaderContentType, "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization := req.Header.Get(HeaderAuthorization)
+		if _, isRevoked := revoked.Load(authorization); isRevoked || authorization == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(authorization))
+	}))
+	defer apiServer.Close()
+
+	ctx := context.Background()
+
+	t.Run("Caches the token and single-flights the refresh", func(t *testing.T) {
+		atomic.StoreInt32(&tokensIssued, 0)
+		client := NewHttpClient().SetAuth(NewOAuth2ClientCredentials(tokenServer.URL, "client", "secret", "read", "write"))
+
+		var wg sync.WaitGroup
+		results := make([]string, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := client.NewGetRequest(ctx, apiServer.URL).WriteBodyTo(&results[i]).Do()
+				r.NoError(err)
+			}(i)
+		}
+		wg.Wait()
+
+		r.Equal(int32(1), atomic.LoadInt32(&tokensIssued))
+		for _, result := range results {
+			r.Equal("Bearer token-1", result)
+		}
+	})
+
+	t.Run("Retries once on 401 with a fresh token", func(t *testing.T) {
+		atomic.StoreInt32(&tokensIssued, 0)
+		client := NewHttpClient().SetAuth(NewOAuth2ClientCredentials(tokenServer.URL, "client", "secret", "read", "write"))
+
+		var result string
+		_, err := client.NewPostRequest(ctx, apiServer.URL, "body").WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("Bearer token-1", result)
+
+		revoked.Store("Bearer token-1", true)
+
+		_, err = client.NewPostRequest(ctx, apiServer.URL, "body").WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("Bearer token-2", result)
+	})
+
+	t.Run("Refreshes before expiry", func(t *testing.T) {
+		atomic.StoreInt32(&tokensIssued, 0)
+		now := time.Now()
+		auth := NewOAuth2ClientCredentials(tokenServer.URL, "client", "secret", "read", "write").SetExpiryDelta(time.Minute)
+		auth.now = func() time.Time { return now }
+
+		token, err := auth.Token(ctx)
+		r.NoError(err)
+		r.Equal("token-1", token.AccessToken)
+		r.Equal("Bearer", token.TokenType)
+
+		now = now.Add(58 * time.Minute)
+		token, err = auth.Token(ctx)
+		r.NoError(err)
+		r.Equal("token-1", token.AccessToken)
+
+		now = now.Add(time.Minute + time.Second)
+		token, err = auth.Token(ctx)
+		r.NoError(err)
+		r.Equal("token-2", token.AccessToken)
+	})
+
+	t.Run("Waiting callers request the token again when the first caller gives up", func(t *testing.T) {
+		auth := NewOAuth2ClientCredentials(tokenServer.URL, "client", "secret", "read", "write")
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := auth.Token(timeoutCtx)
+			firstErr <- err
+		}()
+		r.Eventually(func() bool {
+			auth.mu.Lock()
+			defer auth.mu.Unlock()
+			return auth.refreshing != nil
+		}, time.Second, time.Millisecond)
+
+		token, err := auth.Token(ctx)
+		r.NoError(err)
+		r.NotEmpty(token.AccessToken)
+		r.ErrorIs(<-firstErr, context.DeadlineExceeded)
+	})
+
+	t.Run("Token endpoint error", func(t *testing.T) {
+		client := NewHttpClient().SetAuth(NewOAuth2ClientCredentials(tokenServer.URL, "client", "wrong", "read", "write"))
+
+		_, err := client.NewGetRequest(ctx, apiServer.URL).Do()
+		r.ErrorContains(err, "authentication: oauth2 token request")
+	})
+}
+
+func TestAuthHelpers(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", req.Header.Get(HeaderAuthorization), req.Header.Get("X-Api-Key"), req.URL.Query().Get("key"))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	t.Run("Basic auth", func(t *testing.T) {
+		var result string
+		_, err := NewHttpClient().SetBasicAuth("user", "pass").NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("Basic dXNlcjpwYXNz||", result)
+	})
+
+	t.Run("Bearer token func is evaluated per request", func(t *testing.T) {
+		var calls int32
+		client := NewHttpClient().SetBearerToken(func(ctx context.Context) (string, error) {
+			return fmt.Sprintf("token-%d", atomic.AddInt32(&calls, 1)), nil
+		})
+
+		for i := 1; i <= 2; i++ {
+			var result string
+			_, err := client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+			r.NoError(err)
+			r.Equal(fmt.Sprintf("Bearer token-%d||", i), result)
+		}
+
+		_, err := NewHttpClient().SetBearerToken(42).NewGetRequest(ctx, server.URL).Do()
+		r.ErrorContains(err, "unsupported bearer token type")
+	})
+
+	t.Run("API key", func(t *testing.T) {
+		var result string
+		_, err := NewHttpClient().SetAPIKey(APIKeyInHeader, "X-Api-Key", "secret").NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("|secret|", result)
+
+		_, err = NewHttpClient().SetAPIKey(APIKeyInQuery, "key", "secret").NewGetRequest(ctx, server.URL+"?a=1").WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("||secret", result)
+	})
+
+	t.Run("Request level override does not affect the client", func(t *testing.T) {
+		client := NewHttpClient().SetBearerToken("client-token")
+
+		var result string
+		_, err := client.NewGetRequest(ctx, server.URL).SetBasicAuth("user", "pass").WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("Basic dXNlcjpwYXNz||", result)
+
+		_, err = client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("Bearer client-token||", result)
+	})
+
+	t.Run("API key name is redacted", func(t *testing.T) {
+		redactor := NewRedactor()
+		options := &RequestOptions{Redactor: redactor}
+		options.SetAPIKey(APIKeyInHeader, "X-Partner-Key", "secret")
+
+		r.Equal("[REDACTED]", options.redactor().RedactHeaderValue("X-Partner-Key", "secret"))
+		r.Equal("secret", redactor.RedactHeaderValue("X-Partner-Key", "secret"), "the original redactor is not modified")
+	})
+
+	t.Run("API key name is redacted with a Redactor set afterwards", func(t *testing.T) {
+		client := NewHttpClient().SetRedactor(nil).SetAPIKey(APIKeyInQuery, "partner_key", "secret")
+		client.SetRedactor(NewEmptyRedactor())
+
+		command, err := client.NewGetRequest(ctx, server.URL).ToCurl()
+		r.NoError(err)
+		r.NotContains(command, "secret")
+
+		command, err = client.NewGetRequest(ctx, server.URL).SetRedactor(NewRedactor()).ToCurl()
+		r.NoError(err)
+		r.NotContains(command, "secret")
+	})
+}
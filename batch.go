@@ -0,0 +1,192 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+const defaultBatchConcurrency = 10
+
+// ErrBatchAborted is the cause of the requests that were not sent, or were canceled, because another request of a fail-fast batch failed.
+var ErrBatchAborted = errors.New("batch aborted after a failed request")
+
+// BatchResult is the outcome of a single request of the batch.
+type BatchResult struct {
+	// Index is the position of the request in the batch.
+	Index    int
+	Request  *Request
+	Response *http.Response
+	// Err is the error returned by Request.Do, or a *RequestError with the ErrorPhaseRejected phase if the request was not sent.
+	Err error
+}
+
+// BatchProgress describes the state of the batch after a request has completed.
+type BatchProgress struct {
+	Completed int
+	Failed    int
+	Total     int
+	// Result is the result of the request that has just completed.
+	Result BatchResult
+}
+
+// BatchProgressHook is called after every completed request of the batch. The calls are never concurrent.
+type BatchProgressHook func(progress BatchProgress)
+
+// BatchExecutor executes many requests concurrently with bounded parallelism.
+// Each request keeps its own body, options and WriteBodyTo destination, the results are returned in the order of the requests.
+// In the default collect-all mode every request is executed, in the fail-fast mode the first failure cancels the requests in flight and skips the rest.
+type BatchExecutor struct {
+	concurrency int
+	failFast    bool
+	onProgress  BatchProgressHook
+}
+
+// NewBatchExecutor creates a BatchExecutor running up to 10 requests at a time in the collect-all mode.
+func NewBatchExecutor() *BatchExecutor {
+	return &BatchExecutor{concurrency: defaultBatchConcurrency}
+}
+
+// SetConcurrency sets the maximum number of requests executed at a time. Zero or a negative value runs all requests at once.
+func (b *BatchExecutor) SetConcurrency(concurrency int) *BatchExecutor {
+	b.concurrency = concurrency
+	return b
+}
+
+// SetFailFast enables the fail-fast mode: once a request fails, the requests in flight are canceled and the remaining ones are not sent.
+func (b *BatchExecutor) SetFailFast(failFast bool) *BatchExecutor {
+	b.failFast = failFast
+	return b
+}
+
+// SetOnProgress sets the hook called after every completed request.
+func (b *BatchExecutor) SetOnProgress(hook BatchProgressHook) *BatchExecutor {
+	b.onProgress = hook
+	return b
+}
+
+// Execute runs the requests and returns their results in the same order. Canceling the context cancels the whole batch.
+// The returned error is the first failure that occurred, or nil if all requests succeeded.
+// The caller is responsible for closing the bodies of the successful responses if WriteBodyTo was not used.
+func (b *BatchExecutor) Execute(ctx context.Context, requests ...*Request) ([]BatchResult, error) {
+	results := make([]BatchResult, len(requests))
+	if len(requests) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	concurrency := b.concurrency
+	if concurrency <= 0 || concurrency > len(requests) {
+		concurrency = len(requests)
+	}
+
+	indexes := make(chan int)
+	completed := make(chan BatchResult)
+
+	// The failure is recorded by the worker, so the next request is not started before the batch is canceled.
+	var abortOnce sync.Once
+	var abortErr error
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result := executeBatchRequest(ctx, index, requests[index])
+				if b.failFast && result.Err != nil {
+					abortOnce.Do(func() {
+						abortErr = result.Err
+						cancel(ErrBatchAborted)
+					})
+				}
+				completed <- result
+			}
+		}()
+	}
+
+	go func() {
+		defer close(indexes)
+		for index := range requests {
+			indexes <- index
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(completed)
+	}()
+
+	var firstErr error
+	progress := BatchProgress{Total: len(requests)}
+	for result := range completed {
+		results[result.Index] = result
+
+		progress.Completed++
+		if result.Err != nil {
+			progress.Failed++
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+		}
+
+		if b.onProgress != nil {
+			progress.Result = result
+			b.onProgress(progress)
+		}
+	}
+
+	if abortErr != nil {
+		return results, abortErr
+	}
+
+	return results, firstErr
+}
+
+// executeBatchRequest runs the request with a context canceled together with the batch, unless the request is already completed.
+// A shallow copy of the request is run with that context, so the same request can be listed in several batches, or several times in one.
+func executeBatchRequest(batchCtx context.Context, index int, r *Request) BatchResult {
+	result := BatchResult{Index: index, Request: r}
+
+	if batchCtx.Err() != nil {
+		result.Err = r.requestError(ErrorPhaseRejected, nil, context.Cause(batchCtx))
+		return result
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-batchCtx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	run := *r
+	run.ctx = ctx
+	result.Response, result.Err = run.Do()
+	close(stop)
+
+	// The body of a response which is not consumed by WriteBodyTo is read by the caller after the batch is completed.
+	if result.Err == nil && !r.unmarshalResult && result.Response != nil && result.Response.Body != nil {
+		result.Response.Body = &cancelOnCloseBody{ReadCloser: result.Response.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+
+	if result.Err != nil && errors.Is(result.Err, context.Canceled) && context.Cause(batchCtx) == ErrBatchAborted {
+		result.Err = r.requestError(ErrorPhaseRejected, result.Response, ErrBatchAborted)
+	}
+
+	return result
+}
+
+// Batch executes the requests concurrently, up to 10 at a time, and returns their results in the same order.
+// Every request is executed even if some of them fail, use a BatchExecutor for the fail-fast mode, other limits or progress reporting.
+func (c *HttpClient) Batch(ctx context.Context, requests ...*Request) ([]BatchResult, error) {
+	return NewBatchExecutor().Execute(ctx, requests...)
+}
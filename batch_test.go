@@ -0,0 +1,175 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchExecutor(t *testing.T) {
+	r := require.New(t)
+
+	var inFlight, maxInFlight, hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			current := atomic.LoadInt32(&maxInFlight)
+			if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+				break
+			}
+		}
+
+		switch req.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/slow":
+			select {
+			case <-req.Context().Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		fmt.Fprint(w, req.URL.Path)
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+	ctx := context.Background()
+
+	reset := func() {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&maxInFlight, 0)
+	}
+
+	t.Run("Results are ordered and parallelism is bounded", func(t *testing.T) {
+		reset()
+
+		bodies := make([]string, 20)
+		requests := make([]*Request, len(bodies))
+		for i := range requests {
+			requests[i] = client.NewGetRequest(ctx, fmt.Sprintf("%s/item/%d", server.URL, i)).WriteBodyTo(&bodies[i])
+		}
+
+		var progress []BatchProgress
+		results, err := NewBatchExecutor().
+			SetConcurrency(3).
+			SetOnProgress(func(p BatchProgress) { progress = append(progress, p) }).
+			Execute(ctx, requests...)
+		r.NoError(err)
+		r.Len(results, 20)
+
+		for i, result := range results {
+			r.Equal(i, result.Index)
+			r.Same(requests[i], result.Request)
+			r.NoError(result.Err)
+			r.Equal(http.StatusOK, result.Response.StatusCode)
+			r.Equal(fmt.Sprintf("/item/%d", i), bodies[i])
+		}
+
+		r.LessOrEqual(atomic.LoadInt32(&maxInFlight), int32(3))
+		r.Len(progress, 20)
+		r.Equal(20, progress[19].Completed)
+		r.Equal(20, progress[19].Total)
+		r.Zero(progress[19].Failed)
+	})
+
+	t.Run("Collect-all executes every request and reports typed errors", func(t *testing.T) {
+		reset()
+
+		results, err := client.Batch(ctx,
+			client.NewGetRequest(ctx, server.URL+"/a"),
+			client.NewGetRequest(ctx, server.URL+"/fail"),
+			client.NewGetRequest(ctx, server.URL+"/b"),
+		)
+		r.Error(err)
+		r.Equal(int32(3), atomic.LoadInt32(&hits))
+
+		var requestErr *RequestError
+		r.ErrorAs(results[1].Err, &requestErr)
+		r.Equal(ErrorPhaseStatus, requestErr.Phase)
+		r.Equal(http.StatusInternalServerError, requestErr.StatusCode)
+
+		// Bodies not consumed with WriteBodyTo stay readable after the batch is completed
+		for _, i := range []int{0, 2} {
+			r.NoError(results[i].Err)
+			body, err := io.ReadAll(results[i].Response.Body)
+			r.NoError(err)
+			r.NoError(results[i].Response.Body.Close())
+			r.Contains(string(body), "/")
+		}
+	})
+
+	t.Run("Fail-fast cancels requests in flight and skips the rest", func(t *testing.T) {
+		reset()
+
+		requests := []*Request{
+			client.NewGetRequest(ctx, server.URL+"/slow"),
+			client.NewGetRequest(ctx, server.URL+"/fail"),
+		}
+		for i := 0; i < 5; i++ {
+			requests = append(requests, client.NewGetRequest(ctx, server.URL+"/a"))
+		}
+
+		start := time.Now()
+		results, err := NewBatchExecutor().SetConcurrency(2).SetFailFast(true).Execute(ctx, requests...)
+		r.Error(err)
+		r.Less(time.Since(start), time.Second)
+
+		var requestErr *RequestError
+		r.ErrorAs(err, &requestErr)
+		r.Equal(http.StatusInternalServerError, requestErr.StatusCode)
+
+		r.ErrorIs(results[0].Err, ErrBatchAborted)
+		for _, result := range results[2:] {
+			r.ErrorIs(result.Err, ErrBatchAborted)
+			r.ErrorAs(result.Err, &requestErr)
+			r.Equal(ErrorPhaseRejected, requestErr.Phase)
+		}
+		r.Equal(int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Canceled context cancels the batch", func(t *testing.T) {
+		reset()
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		results, err := client.Batch(ctx, client.NewGetRequest(context.Background(), server.URL+"/slow"))
+		r.Error(err)
+		r.True(errors.Is(results[0].Err, context.Canceled) || errors.Is(results[0].Err, context.DeadlineExceeded))
+	})
+
+	t.Run("Same request listed twice", func(t *testing.T) {
+		reset()
+		request := client.NewGetRequest(ctx, server.URL+"/same")
+
+		results, err := client.Batch(ctx, request, request)
+		r.NoError(err)
+		r.Equal(int32(2), atomic.LoadInt32(&hits))
+		for _, result := range results {
+			r.Same(request, result.Request)
+			r.NoError(result.Response.Body.Close())
+		}
+		r.Equal(ctx, request.ctx, "the context of the request is not modified")
+	})
+
+	t.Run("Empty batch", func(t *testing.T) {
+		results, err := client.Batch(ctx)
+		r.NoError(err)
+		r.Empty(results)
+	})
+}
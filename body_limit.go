@@ -0,0 +1,53 @@
+package httpreqx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is returned when reading the response body beyond the limit set with SetMaxResponseBodySize.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// limitedBody fails with ErrBodyTooLarge once the body turns out to be longer than the limit.
+// The bytes up to the limit are returned as usual, so the body of a response with the exact limit size can be read completely.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+
+	if b.remaining <= 0 {
+		// One more byte tells a body of the exact limit size apart from a longer one.
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			b.exceeded = true
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// limitResponseBody wraps the response body with the limit, before it is read by the dumps, the BodyUnmarshaler or the caller.
+func (r *Request) limitResponseBody(resp *http.Response) {
+	limit := r.options.MaxResponseBodySize
+	if limit <= 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit}
+}
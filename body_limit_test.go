@@ -0,0 +1,157 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxResponseBodySize(t *testing.T) {
+	r := require.New(t)
+
+	large := `{"data":"` + strings.Repeat("x", 100) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write(bytes.Repeat([]byte("e"), 2*maxDumpedBodySize))
+		default:
+			_, _ = w.Write([]byte(large))
+		}
+	}))
+	defer server.Close()
+
+	client := NewHttpClient().SetBodyUnmarshaler(NewJSONBodyUnmarshaler()).SetMaxResponseBodySize(50)
+	ctx := context.Background()
+
+	t.Run("Unmarshaling fails beyond the limit", func(t *testing.T) {
+		var result map[string]string
+		_, err := client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+		r.ErrorIs(err, ErrBodyTooLarge)
+
+		var requestErr *RequestError
+		r.ErrorAs(err, &requestErr)
+		r.Equal(ErrorPhaseUnmarshal, requestErr.Phase)
+	})
+
+	t.Run("Body of the exact limit size is read", func(t *testing.T) {
+		var result map[string]string
+		_, err := client.NewGetRequest(ctx, server.URL).SetMaxResponseBodySize(int64(len(large))).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Len(result["data"], 100)
+	})
+
+	t.Run("Request level limit overrides the client", func(t *testing.T) {
+		var result map[string]string
+		_, err := client.NewGetRequest(ctx, server.URL).SetMaxResponseBodySize(0).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal(int64(50), client.requestOptions.MaxResponseBodySize)
+	})
+
+	t.Run("Body read by the caller is limited", func(t *testing.T) {
+		resp, err := client.NewGetRequest(ctx, server.URL).Do()
+		r.NoError(err)
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		r.ErrorIs(err, ErrBodyTooLarge)
+		r.Len(data, 50)
+	})
+
+	t.Run("Downloads are not limited", func(t *testing.T) {
+		dest := &writerAtBuffer{}
+		_, err := client.Download(ctx, server.URL, dest)
+		r.NoError(err)
+		r.Equal(large, string(dest.Bytes()))
+	})
+
+	t.Run("Dump of a large error body is truncated", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			resp, err := NewHttpClient().NewGetRequest(ctx, server.URL+"/error").SetDumpOnError().Do()
+			r.Error(err)
+			defer resp.Body.Close()
+
+			// The dumped part is kept in the body
+			data, err := io.ReadAll(resp.Body)
+			r.NoError(err)
+			r.Len(data, 2*maxDumpedBodySize)
+		})
+
+		r.Contains(output, "<truncated>")
+		r.Contains(output, strings.Repeat("e", maxDumpedBodySize)+" <truncated>")
+		r.NotContains(output, strings.Repeat("e", maxDumpedBodySize+1))
+	})
+
+	t.Run("Dump respects the limit", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			resp, err := client.NewGetRequest(ctx, server.URL+"/error").SetBodyUnmarshaler(NewNoopBodyUnmarshaler()).SetDumpOnError().Do()
+			r.Error(err)
+			_ = resp.Body.Close()
+		})
+
+		r.Contains(output, "Response body: "+strings.Repeat("e", 50)+" <truncated>")
+	})
+
+	t.Run("Truncated JSON is not printed when it can not be redacted", func(t *testing.T) {
+		resp := &http.Response{
+			Status:     "500 Internal Server Error",
+			StatusCode: http.StatusInternalServerError,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"token":"secret","data":"` + strings.Repeat("x", maxDumpedBodySize) + `"}`)),
+		}
+
+		output := captureStdout(t, func() {
+			dumpResponse(resp, NewRedactor())
+		})
+		r.NotContains(output, "secret")
+		r.Contains(output, "not printed as it can not be redacted")
+	})
+}
+
+func TestLimitedBody(t *testing.T) {
+	r := require.New(t)
+
+	body := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), remaining: 10}
+	data, err := io.ReadAll(body)
+	r.NoError(err)
+	r.Equal("0123456789", string(data))
+
+	body = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), remaining: 9}
+	data, err = io.ReadAll(body)
+	r.ErrorIs(err, ErrBodyTooLarge)
+	r.Equal("012345678", string(data))
+
+	_, err = body.Read(make([]byte, 1))
+	r.ErrorIs(err, ErrBodyTooLarge)
+}
+
+// captureStdout returns everything printed to the standard output by the function.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	fn()
+
+	_ = writer.Close()
+	os.Stdout = stdout
+	return <-output
+}
@@ -0,0 +1,165 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBulkheadFull is returned by Request.Do when the concurrency limit is reached and the request can't wait in the queue,
+// or its queue timeout elapsed.
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// BulkheadStats are the current counts of the concurrency limiter, e.g. to be exported as gauges.
+type BulkheadStats struct {
+	InFlight int
+	Queued   int
+	// InFlightByHost is only tracked when the per host limit is set.
+	InFlightByHost map[string]int
+}
+
+// bulkhead limits the number of concurrent requests made with a client, globally and per host.
+// Requests beyond the capacity wait in a bounded queue. Waiting requests are admitted as soon as a slot is free, not strictly in arrival order.
+type bulkhead struct {
+	maxInFlight  int
+	maxPerHost   int
+	queueSize    int
+	queueTimeout time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	hosts    map[string]int
+	queued   int
+	released chan struct{}
+}
+
+func newBulkhead() *bulkhead {
+	return &bulkhead{
+		hosts:    make(map[string]int),
+		released: make(chan struct{}),
+	}
+}
+
+// clone returns a bulkhead with the same limits and its own slots and queue.
+func (b *bulkhead) clone() *bulkhead {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	clone := newBulkhead()
+	clone.maxInFlight = b.maxInFlight
+	clone.maxPerHost = b.maxPerHost
+	clone.queueSize = b.queueSize
+	clone.queueTimeout = b.queueTimeout
+	return clone
+}
+
+// configure changes the limits under the lock, the waiting requests are woken up as the new limits may admit them.
+func (b *bulkhead) configure(fn func(b *bulkhead)) {
+	b.mu.Lock()
+	fn(b)
+	close(b.released)
+	b.released = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// acquire takes a slot for the request to the host, waiting in the queue if needed. The returned function releases the slot.
+func (b *bulkhead) acquire(ctx context.Context, host string) (func(), error) {
+	b.mu.Lock()
+	if b.admit(host) {
+		b.mu.Unlock()
+		return b.releaseFunc(host), nil
+	}
+
+	if b.queued >= b.queueSize {
+		b.mu.Unlock()
+		return nil, ErrBulkheadFull
+	}
+	b.queued++
+
+	queueTimeout := b.queueTimeout
+	var timeout <-chan time.Time
+	if queueTimeout > 0 {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		released := b.released
+		b.mu.Unlock()
+
+		var err error
+		select {
+		case <-released:
+		case <-timeout:
+			err = fmt.Errorf("%w: queue timeout of %s elapsed", ErrBulkheadFull, queueTimeout)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		b.mu.Lock()
+		if err != nil {
+			b.queued--
+			b.mu.Unlock()
+			return nil, err
+		}
+		if b.admit(host) {
+			b.queued--
+			b.mu.Unlock()
+			return b.releaseFunc(host), nil
+		}
+	}
+}
+
+// admit takes a slot if the limits allow it. It must be called with the lock held.
+func (b *bulkhead) admit(host string) bool {
+	if b.maxInFlight > 0 && b.inFlight >= b.maxInFlight {
+		return false
+	}
+	if b.maxPerHost > 0 && b.hosts[host] >= b.maxPerHost {
+		return false
+	}
+
+	b.inFlight++
+	if b.maxPerHost > 0 {
+		b.hosts[host]++
+	}
+	return true
+}
+
+func (b *bulkhead) releaseFunc(host string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.inFlight--
+			if _, ok := b.hosts[host]; ok {
+				if b.hosts[host]--; b.hosts[host] <= 0 {
+					delete(b.hosts, host)
+				}
+			}
+
+			// Wake up all waiting requests, the ones fitting into the limits take the free slot.
+			close(b.released)
+			b.released = make(chan struct{})
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *bulkhead) stats() BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BulkheadStats{InFlight: b.inFlight, Queued: b.queued}
+	if len(b.hosts) > 0 {
+		stats.InFlightByHost = make(map[string]int, len(b.hosts))
+		for host, count := range b.hosts {
+			stats.InFlightByHost[host] = count
+		}
+	}
+
+	return stats
+}
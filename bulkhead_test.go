@@ -0,0 +1,151 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkhead(t *testing.T) {
+	r := require.New(t)
+
+	release := make(chan struct{})
+	newServer := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/block" {
+				<-release
+			}
+		}))
+	}
+	server, other := newServer(), newServer()
+	defer server.Close()
+	defer other.Close()
+
+	ctx := context.Background()
+
+	startBlocked := func(client *HttpClient, url string, n int) *sync.WaitGroup {
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.NewGetRequest(ctx, url+"/block").Do()
+				r.NoError(err)
+			}()
+		}
+		return &wg
+	}
+
+	t.Run("Queue size and timeout", func(t *testing.T) {
+		release = make(chan struct{})
+		client := NewHttpClient().
+			SetMaxConcurrentRequests(2).
+			SetConcurrencyQueue(1, 50*time.Millisecond)
+
+		wg := startBlocked(client, server.URL, 2)
+		r.Eventually(func() bool { return client.ConcurrencyStats().InFlight == 2 }, time.Second, time.Millisecond)
+
+		queuedErr := make(chan error)
+		go func() {
+			_, err := client.NewGetRequest(ctx, server.URL).Do()
+			queuedErr <- err
+		}()
+		r.Eventually(func() bool { return client.ConcurrencyStats().Queued == 1 }, time.Second, time.Millisecond)
+
+		_, err := client.NewGetRequest(ctx, server.URL).Do()
+		r.ErrorIs(err, ErrBulkheadFull, "the queue is full")
+		var requestErr *RequestError
+		r.True(errors.As(err, &requestErr))
+		r.Equal(ErrorPhaseRejected, requestErr.Phase)
+
+		r.ErrorIs(<-queuedErr, ErrBulkheadFull, "the queue timeout elapsed")
+		r.Equal(BulkheadStats{InFlight: 2}, client.ConcurrencyStats())
+
+		close(release)
+		wg.Wait()
+		r.Equal(BulkheadStats{}, client.ConcurrencyStats())
+	})
+
+	t.Run("Queued requests are admitted when a slot is free", func(t *testing.T) {
+		release = make(chan struct{})
+		client := NewHttpClient().
+			SetMaxConcurrentRequests(1).
+			SetConcurrencyQueue(10, 0)
+
+		wg := startBlocked(client, server.URL, 3)
+		r.Eventually(func() bool {
+			stats := client.ConcurrencyStats()
+			return stats.InFlight == 1 && stats.Queued == 2
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+		r.Equal(BulkheadStats{}, client.ConcurrencyStats())
+	})
+
+	t.Run("Per host limit", func(t *testing.T) {
+		release = make(chan struct{})
+		client := NewHttpClient().SetMaxConcurrentRequestsPerHost(1)
+
+		wg := startBlocked(client, server.URL, 1)
+		r.Eventually(func() bool { return client.ConcurrencyStats().InFlight == 1 }, time.Second, time.Millisecond)
+
+		_, err := client.NewGetRequest(ctx, server.URL).Do()
+		r.ErrorIs(err, ErrBulkheadFull)
+
+		_, err = client.NewGetRequest(ctx, other.URL).Do()
+		r.NoError(err, "other hosts have their own limit")
+
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("Clones have their own limits and slots", func(t *testing.T) {
+		release = make(chan struct{})
+		client := NewHttpClient().SetMaxConcurrentRequests(1)
+		clone := client.Clone().SetMaxConcurrentRequests(2)
+
+		wg := startBlocked(client, server.URL, 1)
+		r.Eventually(func() bool { return client.ConcurrencyStats().InFlight == 1 }, time.Second, time.Millisecond)
+
+		_, err := client.NewGetRequest(ctx, server.URL).Do()
+		r.ErrorIs(err, ErrBulkheadFull, "the limit of the original is not changed by the clone")
+
+		cloneWg := startBlocked(clone, server.URL, 2)
+		r.Eventually(func() bool { return clone.ConcurrencyStats().InFlight == 2 }, time.Second, time.Millisecond)
+		r.Equal(1, client.ConcurrencyStats().InFlight)
+
+		// Changing the limits while requests are in flight is safe.
+		client.SetMaxConcurrentRequests(3).SetMaxConcurrentRequestsPerHost(2)
+		_, err = client.NewGetRequest(ctx, server.URL).Do()
+		r.NoError(err)
+
+		close(release)
+		wg.Wait()
+		cloneWg.Wait()
+	})
+
+	t.Run("Context while queued", func(t *testing.T) {
+		release = make(chan struct{})
+		client := NewHttpClient().
+			SetMaxConcurrentRequests(1).
+			SetConcurrencyQueue(1, 0)
+
+		wg := startBlocked(client, server.URL, 1)
+		r.Eventually(func() bool { return client.ConcurrencyStats().InFlight == 1 }, time.Second, time.Millisecond)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := client.NewGetRequest(timeoutCtx, server.URL).Do()
+		r.ErrorIs(err, context.DeadlineExceeded)
+
+		close(release)
+		wg.Wait()
+	})
+}
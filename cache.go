@@ -0,0 +1,601 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheMaxEntrySize = 1 << 20
+	maxHeuristicFreshness    = 24 * time.Hour
+)
+
+// credentialHeaders identify the user a response was fetched for, along with the headers masked by the Redactor and the headers set by the Authenticator.
+// Stored responses are only served to requests with the same values.
+var credentialHeaders = []string{HeaderAuthorization, "Proxy-Authorization", HeaderCookie, "X-Api-Key", "X-Auth-Token", HeaderAmzSecurityToken}
+
+// heuristicallyCacheableStatuses can be stored without explicit freshness information (RFC 9110, section 15.1).
+var heuristicallyCacheableStatuses = map[int]struct{}{
+	http.StatusOK:                   {},
+	http.StatusNonAuthoritativeInfo: {},
+	http.StatusNoContent:            {},
+	http.StatusMultipleChoices:      {},
+	http.StatusMovedPermanently:     {},
+	http.StatusPermanentRedirect:    {},
+	http.StatusNotFound:             {},
+	http.StatusMethodNotAllowed:     {},
+	http.StatusGone:                 {},
+	http.StatusRequestURITooLong:    {},
+	http.StatusNotImplemented:       {},
+}
+
+// HTTPCache is an RFC 9111 response cache for GET requests, set with HttpClient.SetCache.
+// It honors the Cache-Control directives max-age, s-maxage, no-store, no-cache, private, public, must-revalidate and stale-while-revalidate
+// of the responses and max-age, min-fresh, max-stale, no-cache and no-store of the requests,
+// as well as Expires, Vary and the heuristic freshness based on Last-Modified.
+// Stale responses with an ETag or Last-Modified are revalidated with a conditional request.
+// Successful unsafe requests (POST, PUT, PATCH, DELETE) invalidate the cached response of their URL.
+// Stored responses are only served to requests with the same credentials (Authorization, Cookie, X-Api-Key and similar headers,
+// the headers masked by the Redactor and the headers set by the Authenticator), as if they were listed in Vary.
+// Cache hits are returned before the BodyUnmarshaler, so WriteBodyTo works as with a network response, see IsCachedResponse.
+type HTTPCache struct {
+	store        CacheStore
+	shared       bool
+	maxEntrySize int64
+	now          func() time.Time
+
+	revalidating sync.Map
+}
+
+// cacheKey identifies a stored response. Only the URL is the key of the CacheStore, so a response stored for other credentials is replaced.
+type cacheKey struct {
+	url         string
+	credentials string
+}
+
+type cacheEntry struct {
+	StatusCode   int               `json:"status_code"`
+	Header       http.Header       `json:"header"`
+	Body         []byte            `json:"body"`
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+	Vary         map[string]string `json:"vary,omitempty"`
+	Credentials  string            `json:"credentials,omitempty"`
+}
+
+// NewHTTPCache creates a private HTTPCache backed by the store, see NewMemoryCacheStore and NewFileCacheStore.
+// Responses with bodies larger than 1 MiB are not stored, see SetMaxEntrySize.
+func NewHTTPCache(store CacheStore) *HTTPCache {
+	return &HTTPCache{
+		store:        store,
+		maxEntrySize: defaultCacheMaxEntrySize,
+		now:          time.Now,
+	}
+}
+
+// SetShared configures the cache as a shared cache. A shared cache does not store responses marked as private
+// and responses to requests with the Authorization header, unless they are explicitly allowed to, and prefers s-maxage over max-age.
+// Use it when the client is used on behalf of multiple users.
+func (c *HTTPCache) SetShared(shared bool) *HTTPCache {
+	c.shared = shared
+	return c
+}
+
+// SetMaxEntrySize sets the maximum body size of a stored response.
+func (c *HTTPCache) SetMaxEntrySize(size int64) *HTTPCache {
+	c.maxEntrySize = size
+	return c
+}
+
+// IsCachedResponse reports whether the response was served from a stored response: by the HTTPCache, either fresh or after a successful revalidation,
+// or by the ValidatorStore in place of a 304 Not Modified response.
+func IsCachedResponse(resp *http.Response) bool {
+	return resp != nil && resp.Header.Get(HeaderXFromCache) == "1"
+}
+
+// roundTrip serves the request from the cache. credentials is the fingerprint of the credentials of the request, see credentialsFingerprint.
+func (c *HTTPCache) roundTrip(req *http.Request, credentials string, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	key := cacheKey{url: req.URL.String(), credentials: credentials}
+
+	if req.Method != http.MethodGet {
+		resp, err := send(req)
+		if err == nil && isUnsafeMethod(req.Method) && resp.StatusCode < 400 {
+			c.store.Delete(key.url)
+		}
+		return resp, err
+	}
+
+	requestCacheControl := parseCacheControl(req.Header)
+	if _, noStore := requestCacheControl["no-store"]; noStore {
+		return send(req)
+	}
+	// Partial responses are not cached, and the cached full responses are not used for range requests.
+	if req.Header.Get(HeaderRange) != "" {
+		return send(req)
+	}
+
+	entry := c.load(key, req)
+	if entry == nil {
+		return c.storeResponse(key, req, c.now(), send)
+	}
+
+	age := entry.age(c.now())
+	lifetime := c.freshnessLifetime(entry)
+	responseCacheControl := parseCacheControl(entry.Header)
+
+	if c.fresh(requestCacheControl, responseCacheControl, age, lifetime) {
+		return entry.response(req, age), nil
+	}
+
+	if c.staleWhileRevalidate(requestCacheControl, responseCacheControl, age, lifetime) {
+		c.revalidateInBackground(key, req, entry, send)
+		return entry.response(req, age), nil
+	}
+
+	return c.revalidate(key, req, entry, send)
+}
+
+func (c *HTTPCache) fresh(requestCacheControl, responseCacheControl map[string]string, age, lifetime time.Duration) bool {
+	if _, noCache := requestCacheControl["no-cache"]; noCache {
+		return false
+	}
+	if _, noCache := responseCacheControl["no-cache"]; noCache {
+		return false
+	}
+
+	if maxAge, ok := cacheControlSeconds(requestCacheControl, "max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := cacheControlSeconds(requestCacheControl, "min-fresh"); ok {
+		age += minFresh
+	}
+	if age < lifetime {
+		return true
+	}
+
+	// The client accepts stale responses, unless the server forbids it.
+	if maxStale, ok := requestCacheControl["max-stale"]; ok && !mustRevalidate(responseCacheControl) {
+		if maxStale == "" {
+			return true
+		}
+		if seconds, err := strconv.ParseInt(maxStale, 10, 64); err == nil {
+			return age < lifetime+time.Duration(seconds)*time.Second
+		}
+	}
+
+	return false
+}
+
+func (c *HTTPCache) staleWhileRevalidate(requestCacheControl, responseCacheControl map[string]string, age, lifetime time.Duration) bool {
+	if _, noCache := requestCacheControl["no-cache"]; noCache {
+		return false
+	}
+	if _, noCache := responseCacheControl["no-cache"]; noCache || mustRevalidate(responseCacheControl) {
+		return false
+	}
+
+	window, ok := cacheControlSeconds(responseCacheControl, "stale-while-revalidate")
+	return ok && age < lifetime+window
+}
+
+// revalidate sends the request with the validators of the entry and serves the entry on 304 Not Modified.
+func (c *HTTPCache) revalidate(key cacheKey, req *http.Request, entry *cacheEntry, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	conditional := conditionalRequest(req, entry, req.Context())
+	if conditional == nil {
+		return c.storeResponse(key, req, c.now(), send)
+	}
+
+	requestTime := c.now()
+	resp, err := send(conditional)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		return c.storeFetched(key, req, requestTime, resp), nil
+	}
+
+	discardBody(resp)
+	entry = c.refresh(key, entry, resp, requestTime)
+
+	return entry.response(req, entry.age(c.now())), nil
+}
+
+func (c *HTTPCache) revalidateInBackground(key cacheKey, req *http.Request, entry *cacheEntry, send func(req *http.Request) (*http.Response, error)) {
+	if _, running := c.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	// The revalidation outlives the request, so it must not be canceled with it.
+	background := conditionalRequest(req, entry, context.Background())
+	if background == nil {
+		background = req.Clone(context.Background())
+	}
+
+	go func() {
+		defer c.revalidating.Delete(key)
+
+		requestTime := c.now()
+		resp, err := send(background)
+		if err != nil {
+			return
+		}
+
+		if resp.StatusCode == http.StatusNotModified {
+			discardBody(resp)
+			c.refresh(key, entry, resp, requestTime)
+			return
+		}
+
+		resp = c.storeFetched(key, req, requestTime, resp)
+		discardBody(resp)
+	}()
+}
+
+// refresh updates the stored entry with the headers of the 304 Not Modified response.
+func (c *HTTPCache) refresh(key cacheKey, entry *cacheEntry, resp *http.Response, requestTime time.Time) *cacheEntry {
+	refreshed := entry.refreshed(resp, requestTime, c.now())
+	c.save(key, refreshed)
+	return refreshed
+}
+
+func (c *HTTPCache) storeResponse(key cacheKey, req *http.Request, requestTime time.Time, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	resp, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.storeFetched(key, req, requestTime, resp), nil
+}
+
+// storeFetched stores the response if it is storable and returns it with a body that can be read again.
+func (c *HTTPCache) storeFetched(key cacheKey, req *http.Request, requestTime time.Time, resp *http.Response) *http.Response {
+	if !c.storable(req, resp) {
+		return resp
+	}
+
+	body, ok := readEntryBody(resp, c.maxEntrySize)
+	if ok {
+		c.save(key, newCacheEntry(req, key.credentials, resp, body, requestTime, c.now()))
+	}
+
+	return resp
+}
+
+func (c *HTTPCache) storable(req *http.Request, resp *http.Response) bool {
+	if _, ok := heuristicallyCacheableStatuses[resp.StatusCode]; !ok {
+		return false
+	}
+
+	cacheControl := parseCacheControl(resp.Header)
+	if _, noStore := cacheControl["no-store"]; noStore {
+		return false
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, public := cacheControl["public"]
+	_, sMaxAge := cacheControl["s-maxage"]
+	if c.shared {
+		if _, private := cacheControl["private"]; private {
+			return false
+		}
+		if req.Header.Get(HeaderAuthorization) != "" && !public && !sMaxAge && !mustRevalidate(cacheControl) {
+			return false
+		}
+	}
+
+	// Without freshness information or validators the response could never be served.
+	_, maxAge := cacheControl["max-age"]
+	return public || maxAge || sMaxAge ||
+		resp.Header.Get(HeaderExpires) != "" ||
+		resp.Header.Get(HeaderLastModified) != "" ||
+		resp.Header.Get(HeaderETag) != ""
+}
+
+func (c *HTTPCache) freshnessLifetime(entry *cacheEntry) time.Duration {
+	cacheControl := parseCacheControl(entry.Header)
+
+	if c.shared {
+		if sMaxAge, ok := cacheControlSeconds(cacheControl, "s-maxage"); ok {
+			return sMaxAge
+		}
+	}
+	if maxAge, ok := cacheControlSeconds(cacheControl, "max-age"); ok {
+		return maxAge
+	}
+
+	date := entry.date()
+	if expires := entry.Header.Get(HeaderExpires); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			// Invalid values, e.g. "0", mean the response is already expired.
+			return 0
+		}
+		return expiresTime.Sub(date)
+	}
+
+	// Heuristic freshness: 10% of the time since the last modification.
+	if lastModified, err := http.ParseTime(entry.Header.Get(HeaderLastModified)); err == nil && lastModified.Before(date) {
+		lifetime := date.Sub(lastModified) / 10
+		if lifetime > maxHeuristicFreshness {
+			lifetime = maxHeuristicFreshness
+		}
+		return lifetime
+	}
+
+	return 0
+}
+
+func (c *HTTPCache) load(key cacheKey, req *http.Request) *cacheEntry {
+	return loadCacheEntry(c.store, key, req)
+}
+
+func (c *HTTPCache) save(key cacheKey, entry *cacheEntry) {
+	saveCacheEntry(c.store, key, entry)
+}
+
+func newCacheEntry(req *http.Request, credentials string, resp *http.Response, body []byte, requestTime, responseTime time.Time) *cacheEntry {
+	entry := &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		Credentials:  credentials,
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if entry.Vary == nil {
+			entry.Vary = make(map[string]string)
+		}
+		entry.Vary[name] = strings.Join(req.Header.Values(name), ",")
+	}
+
+	return entry
+}
+
+// loadCacheEntry returns the stored entry, or nil if there is none or it was stored for different values of the Vary or credential headers.
+func loadCacheEntry(store CacheStore, key cacheKey, req *http.Request) *cacheEntry {
+	data, ok := store.Get(key.url)
+	if !ok {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		store.Delete(key.url)
+		return nil
+	}
+
+	if entry.Credentials != key.credentials {
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return nil
+		}
+	}
+
+	return &entry
+}
+
+// credentialsFingerprint returns the SHA-256 digest of the credential headers, so they are not stored in plain text,
+// or an empty string if the request has none. names are the headers set by the Authenticator, they are checked along with
+// the credentialHeaders and the headers masked by the redactor.
+func credentialsFingerprint(header http.Header, redactor *Redactor, names []string) string {
+	names = append(append([]string{}, names...), credentialHeaders...)
+	if redactor != nil {
+		for name := range redactor.headers {
+			names = append(names, name)
+		}
+	}
+	for i, name := range names {
+		names[i] = http.CanonicalHeaderKey(name)
+	}
+	sort.Strings(names)
+
+	var credentials strings.Builder
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		if values := header.Values(name); len(values) > 0 {
+			credentials.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+		}
+	}
+	if credentials.Len() == 0 {
+		return ""
+	}
+
+	return hashSHA256([]byte(credentials.String()))
+}
+
+func saveCacheEntry(store CacheStore, key cacheKey, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	store.Set(key.url, data)
+}
+
+// readEntryBody reads the body up to maxSize and replaces it with a body that can be read again.
+// Larger bodies are not buffered, the response keeps serving the full body and false is returned.
+func readEntryBody(resp *http.Response, maxSize int64) ([]byte, bool) {
+	if resp.Body == nil {
+		return []byte{}, true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil || int64(len(body)) > maxSize {
+		// The already read part is served along with the rest of the body.
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return nil, false
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, true
+}
+
+// date returns the Date header of the response, or the time it was received if it is missing.
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get(HeaderDate)); err == nil {
+		return date
+	}
+
+	return e.ResponseTime
+}
+
+// age calculates the current age of the response (RFC 9111, section 4.2.3).
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.ParseInt(e.Header.Get(HeaderAge), 10, 64); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+
+	initialAge := apparentAge
+	if correctedAge > initialAge {
+		initialAge = correctedAge
+	}
+
+	return initialAge + now.Sub(e.ResponseTime)
+}
+
+// refreshed returns a copy of the entry with the headers of the 304 Not Modified response.
+func (e *cacheEntry) refreshed(resp *http.Response, requestTime, responseTime time.Time) *cacheEntry {
+	refreshed := *e
+	refreshed.Header = e.Header.Clone()
+	for name, values := range resp.Header {
+		if name == HeaderContentLength {
+			continue
+		}
+		refreshed.Header[name] = values
+	}
+	refreshed.RequestTime = requestTime
+	refreshed.ResponseTime = responseTime
+
+	return &refreshed
+}
+
+// response creates the response served from the cache.
+func (e *cacheEntry) response(req *http.Request, age time.Duration) *http.Response {
+	header := e.Header.Clone()
+	header.Set(HeaderAge, strconv.FormatInt(int64(age/time.Second), 10))
+	header.Set(HeaderXFromCache, "1")
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// conditionalRequest returns a copy of the request with the validators of the entry, or nil if the entry has none.
+// Validators set by the caller are kept.
+func conditionalRequest(req *http.Request, entry *cacheEntry, ctx context.Context) *http.Request {
+	etag := entry.Header.Get(HeaderETag)
+	lastModified := entry.Header.Get(HeaderLastModified)
+	if etag == "" && lastModified == "" {
+		return nil
+	}
+
+	conditional := req.Clone(ctx)
+	if etag != "" && conditional.Header.Get(HeaderIfNoneMatch) == "" {
+		conditional.Header.Set(HeaderIfNoneMatch, etag)
+	}
+	if lastModified != "" && conditional.Header.Get(HeaderIfModifiedSince) == "" {
+		conditional.Header.Set(HeaderIfModifiedSince, lastModified)
+	}
+
+	return conditional
+}
+
+// parseCacheControl returns the Cache-Control directives with lowercase names and unquoted values.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+
+	for _, value := range header.Values(HeaderCacheControl) {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+
+			name, argument, _ := strings.Cut(directive, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(argument), `"`)
+		}
+	}
+
+	return directives
+}
+
+func cacheControlSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, true
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+func mustRevalidate(directives map[string]string) bool {
+	_, mustRevalidate := directives["must-revalidate"]
+	_, proxyRevalidate := directives["proxy-revalidate"]
+	return mustRevalidate || proxyRevalidate
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values(HeaderVary) {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	default:
+		return true
+	}
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,146 @@
+package httpreqx
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore persists the serialized responses of the HTTPCache.
+// Errors are not reported, a failing store behaves as a cache miss. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// MemoryCacheStore is an in-memory CacheStore that evicts the least recently used entries once the total size exceeds the limit.
+type MemoryCacheStore struct {
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCacheStore creates a MemoryCacheStore holding at most maxSize bytes of entries. Larger entries are not stored.
+func NewMemoryCacheStore(maxSize int64) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (s *MemoryCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(element)
+
+	return element.Value.(*memoryCacheEntry).value, true
+}
+
+func (s *MemoryCacheStore) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(key)
+	if int64(len(value)) > s.maxSize {
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryCacheEntry{key: key, value: value})
+	s.size += int64(len(value))
+
+	for s.size > s.maxSize {
+		s.delete(s.lru.Back().Value.(*memoryCacheEntry).key)
+	}
+}
+
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(key)
+}
+
+func (s *MemoryCacheStore) delete(key string) {
+	element, ok := s.entries[key]
+	if !ok {
+		return
+	}
+
+	s.lru.Remove(element)
+	delete(s.entries, key)
+	s.size -= int64(len(element.Value.(*memoryCacheEntry).value))
+}
+
+// Size returns the total size of the stored entries in bytes.
+func (s *MemoryCacheStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+// FileCacheStore is a CacheStore keeping every entry in a file of the directory, named after the SHA-256 of the key.
+// Files are replaced atomically, so the directory can be shared between processes. Entries are never evicted.
+type FileCacheStore struct {
+	dir string
+}
+
+// NewFileCacheStore creates a FileCacheStore in the directory. The directory is created on the first write.
+func NewFileCacheStore(dir string) *FileCacheStore {
+	return &FileCacheStore{dir: dir}
+}
+
+func (s *FileCacheStore) Get(key string) ([]byte, bool) {
+	value, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func (s *FileCacheStore) Set(key string, value []byte) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return
+	}
+
+	file, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(value)
+	if closeErr := file.Close(); err != nil || closeErr != nil {
+		return
+	}
+
+	_ = os.Rename(file.Name(), s.path(key))
+}
+
+func (s *FileCacheStore) Delete(key string) {
+	_ = os.Remove(s.path(key))
+}
+
+func (s *FileCacheStore) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,304 @@
+package httpreqx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// tenantAuth is an Authenticator with a header unknown to the Redactor.
+type tenantAuth string
+
+func (a tenantAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("X-Tenant-Token", string(a))
+	return nil
+}
+
+func (a tenantAuth) HandleUnauthorized(*http.Request, *http.Response) bool {
+	return false
+}
+
+func TestHTTPCache(t *testing.T) {
+	r := require.New(t)
+
+	var hits sync.Map
+	count := func(path string) int64 {
+		v, _ := hits.LoadOrStore(path, new(int64))
+		return atomic.LoadInt64(v.(*int64))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		v, _ := hits.LoadOrStore(req.URL.Path, new(int64))
+		n := atomic.AddInt64(v.(*int64), 1)
+		// Without the Date header the age is measured with the fake clock of the cache.
+		w.Header()[HeaderDate] = nil
+
+		switch req.URL.Path {
+		case "/fresh":
+			if req.Header.Get(HeaderIfNoneMatch) == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set(HeaderCacheControl, "max-age=60")
+			w.Header().Set(HeaderETag, `"v1"`)
+		case "/no-store":
+			w.Header().Set(HeaderCacheControl, "no-store, max-age=60")
+		case "/private":
+			w.Header().Set(HeaderCacheControl, "private, max-age=60")
+		case "/vary":
+			w.Header().Set(HeaderCacheControl, "max-age=60")
+			w.Header().Set(HeaderVary, "Accept-Language")
+			fmt.Fprintf(w, `{"language":%q,"version":%d}`, req.Header.Get(HeaderAcceptLanguage), n)
+			return
+		case "/swr":
+			w.Header().Set(HeaderCacheControl, "max-age=60, stale-while-revalidate=600")
+		case "/heuristic":
+			w.Header().Set(HeaderLastModified, time.Now().Add(-100*time.Hour).UTC().Format(http.TimeFormat))
+		case "/expires":
+			w.Header().Set(HeaderExpires, "0")
+			w.Header().Set(HeaderETag, fmt.Sprintf(`"v%d"`, n))
+		}
+
+		w.Header().Set(HeaderContentType, "application/json")
+		fmt.Fprintf(w, `{"version":%d}`, n)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	newClient := func() (*HttpClient, *HTTPCache, *testClock) {
+		clock := &testClock{now: time.Now()}
+		cache := NewHTTPCache(NewMemoryCacheStore(1 << 20))
+		cache.now = clock.Now
+		return NewHttpClient().SetBodyUnmarshaler(NewJSONBodyUnmarshaler()).SetCache(cache), cache, clock
+	}
+
+	type result struct {
+		Language string `json:"language"`
+		Version  int    `json:"version"`
+	}
+
+	get := func(client *HttpClient, path string, headers map[string]string) (result, *http.Response) {
+		var res result
+		resp, err := client.NewGetRequest(ctx, server.URL+path).SetHeaders(headers).WriteBodyTo(&res).Do()
+		r.NoError(err)
+		return res, resp
+	}
+
+	t.Run("Fresh hit decodes through WriteBodyTo and stale entry is revalidated", func(t *testing.T) {
+		client, _, clock := newClient()
+
+		res, resp := get(client, "/fresh", nil)
+		r.Equal(1, res.Version)
+		r.False(IsCachedResponse(resp))
+
+		res, resp = get(client, "/fresh", nil)
+		r.Equal(1, res.Version)
+		r.True(IsCachedResponse(resp))
+		r.Equal(int64(1), count("/fresh"))
+
+		clock.Advance(2 * time.Minute)
+		res, resp = get(client, "/fresh", nil)
+		r.Equal(1, res.Version, "304 serves the stored body")
+		r.True(IsCachedResponse(resp))
+		r.Equal(int64(2), count("/fresh"))
+
+		res, _ = get(client, "/fresh", nil)
+		r.Equal(int64(2), count("/fresh"), "the revalidated entry is fresh again")
+
+		get(client, "/fresh", map[string]string{HeaderCacheControl: "no-cache"})
+		r.Equal(int64(3), count("/fresh"), "request no-cache forces revalidation")
+	})
+
+	t.Run("no-store and private", func(t *testing.T) {
+		client, cache, _ := newClient()
+
+		get(client, "/no-store", nil)
+		get(client, "/no-store", nil)
+		r.Equal(int64(2), count("/no-store"))
+
+		get(client, "/private", nil)
+		get(client, "/private", nil)
+		r.Equal(int64(1), count("/private"), "a private cache stores private responses")
+
+		cache.SetShared(true)
+		get(client, "/private", map[string]string{HeaderCacheControl: "no-cache"})
+		cache.store.Delete(server.URL + "/private")
+		get(client, "/private", nil)
+		r.Equal(int64(3), count("/private"), "a shared cache does not store private responses")
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		client, _, _ := newClient()
+
+		en, _ := get(client, "/vary", map[string]string{HeaderAcceptLanguage: "en"})
+		en2, resp := get(client, "/vary", map[string]string{HeaderAcceptLanguage: "en"})
+		r.Equal(en, en2)
+		r.True(IsCachedResponse(resp))
+
+		de, resp := get(client, "/vary", map[string]string{HeaderAcceptLanguage: "de"})
+		r.False(IsCachedResponse(resp))
+		r.Equal("de", de.Language)
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		client, _, _ := newClient()
+
+		alice, _ := get(client, "/vary", map[string]string{HeaderAuthorization: "Bearer alice"})
+		alice2, resp := get(client, "/vary", map[string]string{HeaderAuthorization: "Bearer alice"})
+		r.Equal(alice, alice2)
+		r.True(IsCachedResponse(resp))
+
+		bob, resp := get(client, "/vary", map[string]string{HeaderAuthorization: "Bearer bob"})
+		r.False(IsCachedResponse(resp), "a response is not served to other credentials")
+		r.NotEqual(alice.Version, bob.Version)
+
+		_, resp = get(client, "/vary", map[string]string{HeaderCookie: "session=alice"})
+		r.False(IsCachedResponse(resp))
+		_, resp = get(client, "/vary", map[string]string{HeaderCookie: "session=bob"})
+		r.False(IsCachedResponse(resp))
+		_, resp = get(client, "/vary", nil)
+		r.False(IsCachedResponse(resp))
+	})
+
+	t.Run("Credentials of the Authenticator", func(t *testing.T) {
+		client, _, _ := newClient()
+
+		getWithKey := func(key string) (result, *http.Response) {
+			var res result
+			resp, err := client.NewGetRequest(ctx, server.URL+"/vary").
+				SetAPIKey(APIKeyInHeader, "X-Partner-Key", key).
+				WriteBodyTo(&res).
+				Do()
+			r.NoError(err)
+			return res, resp
+		}
+
+		alice, _ := getWithKey("alice")
+		_, resp := getWithKey("alice")
+		r.True(IsCachedResponse(resp))
+
+		bob, resp := getWithKey("bob")
+		r.False(IsCachedResponse(resp), "a response is not served to another API key")
+		r.NotEqual(alice.Version, bob.Version)
+
+		// Headers of custom Authenticators are compared as well, even if the Redactor does not know them.
+		client.SetRedactor(nil)
+		for _, tenant := range []string{"alice", "bob"} {
+			var res result
+			resp, err := client.NewGetRequest(ctx, server.URL+"/vary").
+				SetAuth(tenantAuth(tenant)).
+				WriteBodyTo(&res).
+				Do()
+			r.NoError(err)
+			r.False(IsCachedResponse(resp))
+		}
+	})
+
+	t.Run("stale-while-revalidate", func(t *testing.T) {
+		client, _, clock := newClient()
+
+		get(client, "/swr", nil)
+		clock.Advance(2 * time.Minute)
+
+		res, resp := get(client, "/swr", nil)
+		r.True(IsCachedResponse(resp))
+		r.Equal(1, res.Version, "the stale response is served right away")
+
+		r.Eventually(func() bool {
+			res, resp := get(client, "/swr", nil)
+			return IsCachedResponse(resp) && res.Version == 2
+		}, time.Second, 10*time.Millisecond)
+		r.Equal(int64(2), count("/swr"))
+	})
+
+	t.Run("Heuristic freshness and Expires", func(t *testing.T) {
+		client, _, clock := newClient()
+
+		get(client, "/heuristic", nil)
+		clock.Advance(time.Hour)
+		_, resp := get(client, "/heuristic", nil)
+		r.True(IsCachedResponse(resp), "10% of 100 hours, capped at 24 hours")
+		clock.Advance(24 * time.Hour)
+		_, resp = get(client, "/heuristic", nil)
+		r.False(IsCachedResponse(resp))
+
+		get(client, "/expires", nil)
+		res, resp := get(client, "/expires", nil)
+		r.False(IsCachedResponse(resp), "invalid Expires means already expired")
+		r.Equal(2, res.Version)
+	})
+
+	t.Run("Unsafe requests invalidate", func(t *testing.T) {
+		client, _, _ := newClient()
+
+		get(client, "/fresh", nil)
+		_, err := client.NewDeleteRequest(ctx, server.URL+"/fresh").Do()
+		r.NoError(err)
+
+		_, resp := get(client, "/fresh", nil)
+		r.False(IsCachedResponse(resp))
+	})
+}
+
+func TestCacheStores(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Memory LRU eviction", func(t *testing.T) {
+		store := NewMemoryCacheStore(10)
+		store.Set("a", []byte("1234"))
+		store.Set("b", []byte("1234"))
+		_, ok := store.Get("a")
+		r.True(ok)
+
+		store.Set("c", []byte("1234"))
+		_, ok = store.Get("b")
+		r.False(ok, "the least recently used entry is evicted")
+		_, ok = store.Get("a")
+		r.True(ok)
+		r.Equal(int64(8), store.Size())
+
+		store.Set("d", []byte("too large entry"))
+		_, ok = store.Get("d")
+		r.False(ok)
+	})
+
+	t.Run("Filesystem", func(t *testing.T) {
+		store := NewFileCacheStore(t.TempDir() + "/cache")
+		_, ok := store.Get("key")
+		r.False(ok)
+
+		store.Set("key", []byte("value"))
+		value, ok := store.Get("key")
+		r.True(ok)
+		r.Equal("value", string(value))
+
+		store.Delete("key")
+		_, ok = store.Get("key")
+		r.False(ok)
+	})
+}
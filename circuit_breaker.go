@@ -0,0 +1,329 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCircuitConsecutiveFailures = 5
+	defaultCircuitMinRequests         = 10
+	defaultCircuitWindow              = time.Minute
+	defaultCircuitOpenTimeout         = 30 * time.Second
+	circuitWindowBuckets              = 10
+)
+
+// ErrCircuitOpen is returned by Request.Do when the circuit breaker rejects the request without sending it.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit.
+type CircuitState int
+
+const (
+	// CircuitClosed - requests are sent and their outcomes are counted.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen - requests fail fast with ErrCircuitOpen until the open timeout elapses.
+	CircuitOpen
+	// CircuitHalfOpen - a limited number of probe requests is sent, their outcomes decide whether the circuit is closed or opened again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitKeyFunc returns the key of the circuit the request belongs to. route is the route template of the request, see Request.SetPathParam.
+type CircuitKeyFunc func(method, host, route string) string
+
+// CircuitStateChangeHook is called on every state transition of a circuit.
+type CircuitStateChangeHook func(key string, from, to CircuitState)
+
+// CircuitBreaker stops sending requests to a failing upstream, set with HttpClient.SetCircuitBreaker.
+// Every host has its own circuit by default, see SetKeyFunc. A circuit opens when the consecutive failures reach the threshold,
+// or when the failure ratio within the rolling window reaches the configured ratio.
+// Open circuits reject requests with ErrCircuitOpen before the body is marshaled, after the open timeout a probe request is let through (half-open):
+// a successful probe closes the circuit, a failed one opens it again.
+// Failures are transport errors and responses with 5xx status codes. Requests canceled by the caller are not counted.
+type CircuitBreaker struct {
+	consecutiveFailures int
+	failureRatio        float64
+	minRequests         int
+	window              time.Duration
+	openTimeout         time.Duration
+	halfOpenRequests    int
+	keyFunc             CircuitKeyFunc
+	onStateChange       CircuitStateChangeHook
+	now                 func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state               CircuitState
+	generation          uint64
+	openedAt            time.Time
+	consecutiveFailures int
+	halfOpenInFlight    int
+	buckets             [circuitWindowBuckets]circuitBucket
+}
+
+type circuitBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+type circuitTransition struct {
+	key      string
+	from, to CircuitState
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with the default settings:
+// - 5 consecutive failures open the circuit, the failure ratio is disabled
+// - 1 minute rolling window
+// - 30 seconds open timeout, 1 probe request in the half-open state
+// - a circuit per host
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		consecutiveFailures: defaultCircuitConsecutiveFailures,
+		minRequests:         defaultCircuitMinRequests,
+		window:              defaultCircuitWindow,
+		openTimeout:         defaultCircuitOpenTimeout,
+		halfOpenRequests:    1,
+		keyFunc: func(_, host, _ string) string {
+			return host
+		},
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// SetConsecutiveFailures sets the number of consecutive failures that open the circuit. 0 disables the threshold.
+func (b *CircuitBreaker) SetConsecutiveFailures(failures int) *CircuitBreaker {
+	b.consecutiveFailures = failures
+	return b
+}
+
+// SetFailureRatio configures opening the circuit when the ratio of failures within the rolling window reaches the ratio (0-1),
+// once at least minRequests requests were made. 0 disables the ratio.
+func (b *CircuitBreaker) SetFailureRatio(ratio float64, minRequests int) *CircuitBreaker {
+	b.failureRatio = ratio
+	b.minRequests = minRequests
+	return b
+}
+
+// SetWindow sets the duration of the rolling window of the failure ratio.
+func (b *CircuitBreaker) SetWindow(window time.Duration) *CircuitBreaker {
+	b.window = window
+	return b
+}
+
+// SetOpenTimeout sets how long the circuit stays open before probe requests are let through.
+func (b *CircuitBreaker) SetOpenTimeout(timeout time.Duration) *CircuitBreaker {
+	b.openTimeout = timeout
+	return b
+}
+
+// SetHalfOpenRequests sets the number of concurrent probe requests in the half-open state.
+func (b *CircuitBreaker) SetHalfOpenRequests(requests int) *CircuitBreaker {
+	b.halfOpenRequests = requests
+	return b
+}
+
+// SetKeyFunc sets the function that assigns requests to circuits, e.g. to have a circuit per route.
+func (b *CircuitBreaker) SetKeyFunc(keyFunc CircuitKeyFunc) *CircuitBreaker {
+	b.keyFunc = keyFunc
+	return b
+}
+
+// SetOnStateChange sets the hook that observes the state transitions, e.g. for logging and alerting.
+// It is called synchronously by the request causing the transition, outside of the internal locks.
+func (b *CircuitBreaker) SetOnStateChange(hook CircuitStateChangeHook) *CircuitBreaker {
+	b.onStateChange = hook
+	return b
+}
+
+// State returns the current state of the circuit with the key.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		b.mu.Unlock()
+		return CircuitClosed
+	}
+	transition := b.updateState(key, c)
+	state := c.state
+	b.mu.Unlock()
+
+	b.notify(transition)
+	return state
+}
+
+// allow admits the request or returns ErrCircuitOpen. The returned function must be called with the outcome of an admitted request.
+func (b *CircuitBreaker) allow(method, host, route string) (func(resp *http.Response, err error), error) {
+	key := b.keyFunc(method, host, route)
+
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+
+	transition := b.updateState(key, c)
+	switch {
+	case c.state == CircuitOpen,
+		c.state == CircuitHalfOpen && c.halfOpenInFlight >= b.halfOpenRequests:
+		b.mu.Unlock()
+		b.notify(transition)
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+	case c.state == CircuitHalfOpen:
+		c.halfOpenInFlight++
+	}
+	generation := c.generation
+	b.mu.Unlock()
+	b.notify(transition)
+
+	return func(resp *http.Response, err error) {
+		b.done(key, generation, resp, err)
+	}, nil
+}
+
+func (b *CircuitBreaker) done(key string, generation uint64, resp *http.Response, err error) {
+	canceled := errors.Is(err, context.Canceled)
+	failure := !canceled && (isTransportError(err) || (resp != nil && resp.StatusCode >= 500))
+
+	b.mu.Lock()
+	c := b.circuits[key]
+
+	// Outcomes of requests admitted before the last transition don't describe the current state.
+	if c.generation != generation {
+		b.mu.Unlock()
+		return
+	}
+
+	var transition *circuitTransition
+	switch c.state {
+	case CircuitHalfOpen:
+		c.halfOpenInFlight--
+		switch {
+		case canceled:
+		case failure:
+			transition = b.setState(key, c, CircuitOpen)
+		default:
+			transition = b.setState(key, c, CircuitClosed)
+		}
+	case CircuitClosed:
+		if canceled {
+			break
+		}
+		c.record(b.now(), b.window, failure)
+		if failure {
+			c.consecutiveFailures++
+		} else {
+			c.consecutiveFailures = 0
+		}
+		if b.tripped(c) {
+			transition = b.setState(key, c, CircuitOpen)
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(transition)
+}
+
+func (b *CircuitBreaker) tripped(c *circuit) bool {
+	if b.consecutiveFailures > 0 && c.consecutiveFailures >= b.consecutiveFailures {
+		return true
+	}
+
+	if b.failureRatio <= 0 {
+		return false
+	}
+
+	successes, failures := c.counts(b.now(), b.window)
+	total := successes + failures
+	return total >= b.minRequests && total > 0 && float64(failures)/float64(total) >= b.failureRatio
+}
+
+// updateState moves an open circuit to half-open once the open timeout elapsed.
+func (b *CircuitBreaker) updateState(key string, c *circuit) *circuitTransition {
+	if c.state == CircuitOpen && b.now().Sub(c.openedAt) >= b.openTimeout {
+		return b.setState(key, c, CircuitHalfOpen)
+	}
+
+	return nil
+}
+
+func (b *CircuitBreaker) setState(key string, c *circuit, state CircuitState) *circuitTransition {
+	transition := &circuitTransition{key: key, from: c.state, to: state}
+
+	c.state = state
+	c.generation++
+	c.consecutiveFailures = 0
+	c.halfOpenInFlight = 0
+	c.buckets = [circuitWindowBuckets]circuitBucket{}
+	if state == CircuitOpen {
+		c.openedAt = b.now()
+	}
+
+	return transition
+}
+
+func (b *CircuitBreaker) notify(transition *circuitTransition) {
+	if transition != nil && b.onStateChange != nil {
+		b.onStateChange(transition.key, transition.from, transition.to)
+	}
+}
+
+// record adds the outcome to the bucket of the rolling window the time belongs to.
+func (c *circuit) record(now time.Time, window time.Duration, failure bool) {
+	bucketSize := window / circuitWindowBuckets
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+
+	start := now.Truncate(bucketSize)
+	bucket := &c.buckets[(start.UnixNano()/int64(bucketSize))%circuitWindowBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{start: start}
+	}
+
+	if failure {
+		bucket.failures++
+	} else {
+		bucket.successes++
+	}
+}
+
+// counts returns the outcomes within the rolling window.
+func (c *circuit) counts(now time.Time, window time.Duration) (successes, failures int) {
+	for _, bucket := range c.buckets {
+		if !bucket.start.IsZero() && now.Sub(bucket.start) < window {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+
+	return successes, failures
+}
+
+// isTransportError reports whether the error was returned because no response was received.
+func isTransportError(err error) bool {
+	var requestErr *RequestError
+	return errors.As(err, &requestErr) && requestErr.Phase == ErrorPhaseTransport
+}
@@ -0,0 +1,149 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingMarshaler struct {
+	NoopBodyMarshaler
+	calls int32
+}
+
+func (m *countingMarshaler) Marshal(body interface{}, writer io.Writer) error {
+	atomic.AddInt32(&m.calls, 1)
+	return m.NoopBodyMarshaler.Marshal(body, writer)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	r := require.New(t)
+
+	var failing int32 = 1
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&failing) == 1 && req.URL.Path != "/ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	r.NoError(err)
+	ctx := context.Background()
+
+	t.Run("Opens after consecutive failures and recovers through half-open", func(t *testing.T) {
+		clock := &testClock{now: time.Now()}
+		var transitions []string
+		breaker := NewCircuitBreaker().
+			SetConsecutiveFailures(3).
+			SetOpenTimeout(10 * time.Second).
+			SetOnStateChange(func(key string, from, to CircuitState) {
+				transitions = append(transitions, fmt.Sprintf("%s:%s->%s", key, from, to))
+			})
+		breaker.now = clock.Now
+
+		marshaler := &countingMarshaler{}
+		client := NewHttpClient().SetCircuitBreaker(breaker).SetBodyMarshaler(marshaler)
+
+		atomic.StoreInt32(&hits, 0)
+		for i := 0; i < 3; i++ {
+			_, err := client.NewPostRequest(ctx, server.URL, "body").Do()
+			r.Error(err)
+			r.False(errors.Is(err, ErrCircuitOpen))
+		}
+		r.Equal(CircuitOpen, breaker.State(serverURL.Host))
+
+		_, err := client.NewPostRequest(ctx, server.URL, "body").Do()
+		r.ErrorIs(err, ErrCircuitOpen)
+		var requestErr *RequestError
+		r.True(errors.As(err, &requestErr))
+		r.Equal(ErrorPhaseRejected, requestErr.Phase)
+		r.Equal(int32(3), atomic.LoadInt32(&hits))
+		r.Equal(int32(3), atomic.LoadInt32(&marshaler.calls), "rejected before marshaling")
+
+		clock.Advance(10 * time.Second)
+		_, err = client.NewGetRequest(ctx, server.URL).Do()
+		r.Error(err)
+		r.False(errors.Is(err, ErrCircuitOpen), "the probe is sent")
+		r.Equal(CircuitOpen, breaker.State(serverURL.Host), "the failed probe opens the circuit again")
+
+		clock.Advance(10 * time.Second)
+		atomic.StoreInt32(&failing, 0)
+		defer atomic.StoreInt32(&failing, 1)
+		_, err = client.NewGetRequest(ctx, server.URL).Do()
+		r.NoError(err)
+		r.Equal(CircuitClosed, breaker.State(serverURL.Host))
+
+		host := serverURL.Host
+		r.Equal([]string{
+			host + ":closed->open",
+			host + ":open->half-open",
+			host + ":half-open->open",
+			host + ":open->half-open",
+			host + ":half-open->closed",
+		}, transitions)
+	})
+
+	t.Run("Failure ratio within the rolling window", func(t *testing.T) {
+		clock := &testClock{now: time.Now()}
+		breaker := NewCircuitBreaker().
+			SetConsecutiveFailures(0).
+			SetFailureRatio(0.5, 4).
+			SetWindow(time.Minute)
+		breaker.now = clock.Now
+		client := NewHttpClient().SetCircuitBreaker(breaker)
+
+		// Alternating outcomes never reach consecutive thresholds, but do reach the ratio.
+		for i := 0; i < 3; i++ {
+			path := "/ok"
+			if i%2 == 1 {
+				path = "/fail"
+			}
+			client.NewGetRequest(ctx, server.URL+path).Do()
+		}
+		r.Equal(CircuitClosed, breaker.State(serverURL.Host))
+
+		clock.Advance(2 * time.Minute)
+		client.NewGetRequest(ctx, server.URL+"/fail").Do()
+		r.Equal(CircuitClosed, breaker.State(serverURL.Host), "old outcomes left the window")
+
+		client.NewGetRequest(ctx, server.URL+"/ok").Do()
+		client.NewGetRequest(ctx, server.URL+"/fail").Do()
+		client.NewGetRequest(ctx, server.URL+"/ok").Do()
+		r.Equal(CircuitOpen, breaker.State(serverURL.Host))
+	})
+
+	t.Run("Custom key and ignored outcomes", func(t *testing.T) {
+		breaker := NewCircuitBreaker().
+			SetConsecutiveFailures(1).
+			SetKeyFunc(func(method, host, route string) string {
+				return method + " " + route
+			})
+		client := NewHttpClient().SetCircuitBreaker(breaker)
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/ok").Do()
+		r.NoError(err)
+		_, err = client.NewGetRequest(ctx, server.URL+"/missing/{id}").SetPathParam("id", "1").Do()
+		r.Error(err)
+
+		r.Equal(CircuitClosed, breaker.State("GET /ok"))
+		r.Equal(CircuitOpen, breaker.State("GET /missing/{id}"))
+
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = client.NewGetRequest(canceledCtx, server.URL+"/canceled").Do()
+		r.Error(err)
+		r.Equal(CircuitClosed, breaker.State("GET /canceled"))
+	})
+}
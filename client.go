@@ -8,6 +8,15 @@ import (
 type HttpClient struct {
 	client         *http.Client
 	requestOptions *RequestOptions
+	harRecorder    *HARRecorder
+	metrics        MetricsRecorder
+	tracer         Tracer
+	cache          *HTTPCache
+	validators     *ValidatorStore
+	coalescer      *coalescer
+	circuitBreaker *CircuitBreaker
+	rateLimiter    *RateLimiter
+	bulkhead       *bulkhead
 }
 
 // NewHttpClient creates a new HttpClient with default settings.
@@ -15,6 +24,8 @@ type HttpClient struct {
 // - Timeout: 20 seconds
 // - BodyMarshaler: NoopBodyMarshaler - this marshaler does not modify the request body. Allows to create requests by passing the same type as the standard http.NewRequestWithContext accepts with some additions for convenience (see NewNoopBodyMarshaler).
 // - BodyUnmarshaler: NoopBodyUnmarshaler - this unmarshaler does not modify the response body, just writes it to the destination with some added handling for convenience (see NewNoopBodyUnmarshaler).
+// - Redactor: a Redactor with the default deny-lists of sensitive headers, query parameters and body fields (see NewRedactor).
+// - Tracer: W3CTracer - propagates the W3C traceparent/tracestate headers from the request context (see NewW3CTracer).
 func NewHttpClient() *HttpClient {
 	return &HttpClient{
 		client: &http.Client{
@@ -23,7 +34,9 @@ func NewHttpClient() *HttpClient {
 		requestOptions: &RequestOptions{
 			BodyMarshaler:   NewNoopBodyMarshaler(),
 			BodyUnmarshaler: NewNoopBodyUnmarshaler(),
+			Redactor:        NewRedactor(),
 		},
+		tracer: NewW3CTracer(),
 	}
 }
 
@@ -35,13 +48,79 @@ func (c *HttpClient) Clone() *HttpClient {
 			Timeout: c.client.Timeout,
 		},
 		requestOptions: c.requestOptions.Clone(),
+		harRecorder:    c.harRecorder,
+		metrics:        c.metrics,
+		tracer:         c.tracer,
+		cache:          c.cache,
+		validators:     c.validators,
+		coalescer:      c.coalescer,
+		circuitBreaker: c.circuitBreaker,
+		rateLimiter:    c.rateLimiter,
+	}
+	if c.bulkhead != nil {
+		clone.bulkhead = c.bulkhead.clone()
 	}
 
 	return clone
 }
 
-func (c *HttpClient) do(req *http.Request) (*http.Response, error) {
-	return c.client.Do(req)
+// do sends the request through the coalescer, the cache and the validator store. authHeaders are the headers set by the Authenticator,
+// stored responses are only used for requests with the same values.
+func (c *HttpClient) do(req *http.Request, options *RequestOptions, authHeaders []string) (*http.Response, error) {
+	send := func(req *http.Request) (*http.Response, error) {
+		return c.send(req, options)
+	}
+
+	var credentials string
+	if c.validators != nil || c.cache != nil {
+		credentials = credentialsFingerprint(req.Header, options.redactor(), authHeaders)
+	}
+
+	if validators := c.validators; validators != nil {
+		next := send
+		send = func(req *http.Request) (*http.Response, error) {
+			return validators.roundTrip(req, credentials, next)
+		}
+	}
+
+	if cache := c.cache; cache != nil {
+		next := send
+		send = func(req *http.Request) (*http.Response, error) {
+			return cache.roundTrip(req, credentials, next)
+		}
+	}
+
+	if c.coalescer != nil {
+		return c.coalescer.roundTrip(req, options, send)
+	}
+
+	return send(req)
+}
+
+// send sends the request over the network, recording its HAR entry and timings.
+func (c *HttpClient) send(req *http.Request, options *RequestOptions) (*http.Response, error) {
+	trace := newConnTrace()
+	resp, err := c.client.Do(trace.traceRequest(req))
+
+	if c.harRecorder != nil {
+		c.harRecorder.record(req, resp, err, trace, options.redactor())
+	}
+
+	if onTimings := options.OnTimings; onTimings != nil {
+		if err != nil || resp == nil || resp.Body == nil {
+			onTimings(req, trace.snapshot().timings(), err)
+		} else {
+			resp.Body = &tracedBody{
+				ReadCloser: resp.Body,
+				trace:      trace,
+				onDone: func(err error) {
+					onTimings(req, trace.snapshot().timings(), err)
+				},
+			}
+		}
+	}
+
+	return resp, err
 }
 
 // SetBodyMarshaler sets the BodyMarshaler at the HttpClient level.
@@ -95,6 +174,195 @@ func (c *HttpClient) SetOnResponseReady(onResponseReady OnResponseReadyHook) *Ht
 	return c
 }
 
+// SetOnTimings sets a hook that receives the phase timings (DNS, connect, TLS, time to first byte, body transfer) of every request.
+// It allows to tell slow upstreams (high TimeToFirstByte) apart from connection pool exhaustion (high GetConnection without DNS/Connect).
+// This hook will be called for all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetOnTimings(onTimings OnTimingsHook) *HttpClient {
+	c.requestOptions.SetOnTimings(onTimings)
+	return c
+}
+
+// SetOnUploadProgress sets a hook that receives the number of request body bytes sent and the total size (-1 if unknown).
+// The hook is called at most 10 times per second, the final progress is always reported.
+// This hook will be called for all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetOnUploadProgress(onUploadProgress UploadProgressHook) *HttpClient {
+	c.requestOptions.SetOnUploadProgress(onUploadProgress)
+	return c
+}
+
+// SetOnDownloadProgress sets a hook that receives the number of response body bytes read, by the BodyUnmarshaler or the caller,
+// and the total size taken from the Content-Length header (-1 if unknown).
+// The hook is called at most 10 times per second, the final progress is always reported.
+// This hook will be called for all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetOnDownloadProgress(onDownloadProgress DownloadProgressHook) *HttpClient {
+	c.requestOptions.SetOnDownloadProgress(onDownloadProgress)
+	return c
+}
+
+// SetAuth sets the Authenticator that adds credentials to every request made with this client (see NewOAuth2ClientCredentials).
+// Requests receiving 401 Unauthorized are retried once if the Authenticator is able to refresh the credentials.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetAuth(authenticator Authenticator) *HttpClient {
+	c.requestOptions.SetAuth(authenticator)
+	return c
+}
+
+// SetBasicAuth configures HTTP Basic authentication for all requests made with this client.
+// It replaces the Authenticator set with SetAuth, SetBearerToken or SetAPIKey.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetBasicAuth(username, password string) *HttpClient {
+	c.requestOptions.SetBasicAuth(username, password)
+	return c
+}
+
+// SetBearerToken configures the "Authorization: Bearer <token>" header for all requests made with this client.
+// The token can be a string, func() (string, error) or func(ctx context.Context) (string, error), functions are evaluated for every request.
+// It replaces the Authenticator set with SetAuth, SetBasicAuth or SetAPIKey.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetBearerToken(tokenOrFunc interface{}) *HttpClient {
+	c.requestOptions.SetBearerToken(tokenOrFunc)
+	return c
+}
+
+// SetAPIKey configures sending the API key in the header or query parameter with the given name for all requests made with this client.
+// The name is masked by the Redactor when the requests run, so the key stays out of dumps whenever the Redactor is set.
+// It replaces the Authenticator set with SetAuth, SetBasicAuth or SetBearerToken.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetAPIKey(location APIKeyLocation, name, value string) *HttpClient {
+	c.requestOptions.SetAPIKey(location, name, value)
+	return c
+}
+
+// SetSigner sets the Signer (e.g. SigV4Signer) that signs every request as the last step before it is sent.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetSigner(signer Signer) *HttpClient {
+	c.requestOptions.SetSigner(signer)
+	return c
+}
+
+// SetCache sets the RFC 9111 response cache for the requests made with this client. Passing nil disables caching.
+// Cache hits are served before the BodyUnmarshaler, so WriteBodyTo works the same way, use IsCachedResponse to tell them apart.
+func (c *HttpClient) SetCache(cache *HTTPCache) *HttpClient {
+	c.cache = cache
+	return c
+}
+
+// SetValidatorStore enables automatic conditional requests: the ETag and Last-Modified validators of GET responses are remembered per URL
+// and sent with the next request, a 304 Not Modified response is replaced with the remembered response. Passing nil disables it.
+func (c *HttpClient) SetValidatorStore(store *ValidatorStore) *HttpClient {
+	c.validators = store
+	return c
+}
+
+// SetCoalesce enables sharing one upstream call between concurrent identical GET and HEAD requests made with this client.
+// Requests are identical when the method, the URL and the Accept, Accept-Language and Accept-Encoding headers are equal, see SetCoalesceHeaders.
+// The credential headers (Authorization, Cookie, X-Api-Key and the headers masked by the Redactor) are always compared.
+// The response body is buffered up to the MaxResponseBodySize or 1 MiB, every caller gets its own copy, so each request decodes it into its own WriteBodyTo destination.
+// Larger bodies are streamed to the first request only, the others send their own request.
+// The shared call is made with the context of the first request, if it is canceled the waiting requests make the call again.
+func (c *HttpClient) SetCoalesce(enabled bool) *HttpClient {
+	if !enabled {
+		c.coalescer = nil
+		return c
+	}
+
+	if c.coalescer == nil {
+		c.coalescer = newCoalescer(defaultCoalesceHeaders)
+	}
+	return c
+}
+
+// SetCoalesceHeaders sets the headers that are part of the identity of coalesced requests, in addition to the method, the URL and the credential headers.
+// It enables coalescing if it is not enabled yet.
+func (c *HttpClient) SetCoalesceHeaders(headers ...string) *HttpClient {
+	c.coalescer = newCoalescer(headers)
+	return c
+}
+
+// SetCircuitBreaker sets the CircuitBreaker that rejects requests to failing upstreams with ErrCircuitOpen before anything is marshaled or sent.
+// Passing nil disables it.
+func (c *HttpClient) SetCircuitBreaker(breaker *CircuitBreaker) *HttpClient {
+	c.circuitBreaker = breaker
+	return c
+}
+
+// SetRateLimiter sets the RateLimiter of the client. Request.Do waits for a token before every attempt, until the context of the request is done.
+// Passing nil disables rate limiting.
+func (c *HttpClient) SetRateLimiter(limiter *RateLimiter) *HttpClient {
+	c.rateLimiter = limiter
+	return c
+}
+
+// SetMaxConcurrentRequests limits the number of requests made with this client that are executed at the same time. 0 removes the limit.
+// Requests beyond the limit wait in the queue configured with SetConcurrencyQueue, or fail with ErrBulkheadFull if it is full.
+// A request holds its slot until Do returns, the body of a response handled by the caller is not covered.
+// A clone of the client gets its own slots with the same limits.
+func (c *HttpClient) SetMaxConcurrentRequests(n int) *HttpClient {
+	c.concurrencyLimiter().configure(func(b *bulkhead) {
+		b.maxInFlight = n
+	})
+	return c
+}
+
+// SetMaxConcurrentRequestsPerHost limits the number of requests to every host that are executed at the same time. 0 removes the limit.
+func (c *HttpClient) SetMaxConcurrentRequestsPerHost(n int) *HttpClient {
+	c.concurrencyLimiter().configure(func(b *bulkhead) {
+		b.maxPerHost = n
+	})
+	return c
+}
+
+// SetConcurrencyQueue sets how many requests can wait for a free slot and for how long, before they fail with ErrBulkheadFull.
+// By default there is no queue. A timeout of 0 makes the requests wait until their context is done.
+func (c *HttpClient) SetConcurrencyQueue(size int, timeout time.Duration) *HttpClient {
+	c.concurrencyLimiter().configure(func(b *bulkhead) {
+		b.queueSize = size
+		b.queueTimeout = timeout
+	})
+	return c
+}
+
+// ConcurrencyStats returns the current number of in-flight and queued requests limited by SetMaxConcurrentRequests.
+func (c *HttpClient) ConcurrencyStats() BulkheadStats {
+	if c.bulkhead == nil {
+		return BulkheadStats{}
+	}
+
+	return c.bulkhead.stats()
+}
+
+func (c *HttpClient) concurrencyLimiter() *bulkhead {
+	if c.bulkhead == nil {
+		c.bulkhead = newBulkhead()
+	}
+	return c.bulkhead
+}
+
+// SetMaxResponseBodySize limits the size of the response bodies, reading beyond the limit fails with ErrBodyTooLarge.
+// The limit applies to the BodyUnmarshaler, the dumps of SetDumpOnError and the bodies read by the caller. Zero removes the limit.
+// Downloads made with Download are not limited, they are streamed to the destination.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetMaxResponseBodySize(size int64) *HttpClient {
+	c.requestOptions.SetMaxResponseBodySize(size)
+	return c
+}
+
+// SetRetryPolicy sets the RetryPolicy repeating failed attempts of the requests made with this client. Passing nil disables retries.
+// POST and PATCH requests are only retried with an idempotency key, see SetAutoIdempotencyKey.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetRetryPolicy(policy *RetryPolicy) *HttpClient {
+	c.requestOptions.SetRetryPolicy(policy)
+	return c
+}
+
+// SetAutoIdempotencyKey enables generating a UUID Idempotency-Key header for POST and PATCH requests.
+// The key is generated once per Do call and sent with every attempt, which allows retrying the requests safely.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetAutoIdempotencyKey(enabled bool) *HttpClient {
+	c.requestOptions.SetAutoIdempotencyKey(enabled)
+	return c
+}
+
 // SetDumpOnError configures logging of the request, response and error when an error occurs.
 // http.Request and http.Response bodies will be logged as well, if they are set.
 // Original body passed by the caller code will be logged as well, if it is set.
@@ -105,9 +373,46 @@ func (c *HttpClient) SetDumpOnError() *HttpClient {
 	return c
 }
 
+// SetCurlOnError configures printing of the error and the failed request as a curl command when an error occurs, instead of the raw dump set by SetDumpOnError.
+// The command is built from the final http.Request, sensitive data is masked with the configured Redactor.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetCurlOnError() *HttpClient {
+	c.requestOptions.SetCurlOnError()
+	return c
+}
+
 // SetStackTraceEnabled enables or disables the stack trace in the error if it occurs.
 // This will affect all requests made with this client unless overridden at the request level.
 func (c *HttpClient) SetStackTraceEnabled(enabled bool) *HttpClient {
 	c.requestOptions.SetStackTraceEnabled(enabled)
 	return c
 }
+
+// SetRedactor sets the Redactor used to mask sensitive data in dumps and error messages.
+// Passing nil disables redaction.
+// This will affect all requests made with this client unless overridden at the request level.
+func (c *HttpClient) SetRedactor(redactor *Redactor) *HttpClient {
+	c.requestOptions.SetRedactor(redactor)
+	return c
+}
+
+// SetHARRecorder attaches a HARRecorder that records every request/response pair made with this client.
+// Passing nil detaches the recorder. Cloned clients share the recorder with the original client.
+func (c *HttpClient) SetHARRecorder(recorder *HARRecorder) *HttpClient {
+	c.harRecorder = recorder
+	return c
+}
+
+// SetMetricsRecorder sets the MetricsRecorder that receives the request count, in-flight gauge, latency and response size of every request made with this client.
+// Passing nil disables the metrics. Cloned clients share the recorder with the original client.
+func (c *HttpClient) SetMetricsRecorder(metrics MetricsRecorder) *HttpClient {
+	c.metrics = metrics
+	return c
+}
+
+// SetTracer sets the Tracer that starts a span for every request attempt and injects the propagation headers into the outgoing requests.
+// Passing nil disables tracing and propagation. Cloned clients share the tracer with the original client.
+func (c *HttpClient) SetTracer(tracer Tracer) *HttpClient {
+	c.tracer = tracer
+	return c
+}
@@ -102,6 +102,12 @@ func TestHttpClient(t *testing.T) {
 		r.NotNil(client.requestOptions.OnResponseReady)
 	})
 
+	t.Run("SetOnTimings", func(t *testing.T) {
+		client := NewHttpClient()
+		client.SetOnTimings(func(req *http.Request, timings Timings, err error) {})
+		r.NotNil(client.requestOptions.OnTimings)
+	})
+
 	t.Run("SetDumpOnError", func(t *testing.T) {
 		client := NewHttpClient()
 		client.SetDumpOnError()
@@ -109,6 +115,12 @@ func TestHttpClient(t *testing.T) {
 		r.Len(client.requestOptions.OnErrorHooks, 1)
 	})
 
+	t.Run("SetCurlOnError", func(t *testing.T) {
+		client := NewHttpClient()
+		client.SetDumpOnError().SetCurlOnError()
+		r.Len(client.requestOptions.OnErrorHooks, 1)
+	})
+
 	t.Run("SetStackTraceEnabled", func(t *testing.T) {
 		client := NewHttpClient()
 		client.SetStackTraceEnabled(true)
@@ -328,6 +340,19 @@ func TestRequest(t *testing.T) {
 		r.Len(req.options.OnErrorHooks, 1)
 	})
 
+	t.Run("Request-level settings do not affect the client", func(t *testing.T) {
+		client := NewHttpClient().SetHeader("X-Client", "client")
+		ctx := context.Background()
+
+		req := client.NewGetRequest(ctx, "/test").
+			SetHeader("X-Request", "request").
+			SetBodyUnmarshaler(NewJSONBodyUnmarshaler())
+
+		r.Equal(map[string]string{"X-Client": "client", "X-Request": "request"}, req.options.Headers)
+		r.Equal(map[string]string{"X-Client": "client"}, client.requestOptions.Headers)
+		r.IsType(&NoopBodyUnmarshaler{}, client.requestOptions.BodyUnmarshaler)
+	})
+
 	t.Run("SetStackTraceEnabled", func(t *testing.T) {
 		client := NewHttpClient()
 		ctx := context.Background()
@@ -1050,6 +1075,36 @@ func TestRequestExecution(t *testing.T) {
 		r.Contains(err.Error(), "body unmarshaler is not set")
 	})
 
+	t.Run("Request with OnTimings", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("timed"))
+		}))
+		defer server.Close()
+
+		var calls []Timings
+		client := NewHttpClient().SetOnTimings(func(req *http.Request, timings Timings, err error) {
+			r.NoError(err)
+			calls = append(calls, timings)
+		})
+		ctx := context.Background()
+
+		for i := 0; i < 2; i++ {
+			var result string
+			_, err := client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+			r.NoError(err)
+			r.Equal("timed", result)
+		}
+
+		r.Len(calls, 2)
+		r.False(calls[0].ConnectionReused)
+		r.Greater(calls[0].Connect, time.Duration(0))
+		r.GreaterOrEqual(calls[0].TimeToFirstByte, 20*time.Millisecond)
+		r.GreaterOrEqual(calls[0].Total, calls[0].TimeToFirstByte)
+		r.True(calls[1].ConnectionReused)
+		r.Equal(time.Duration(0), calls[1].Connect)
+	})
+
 	t.Run("Request with response timeout", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			time.Sleep(100 * time.Millisecond)
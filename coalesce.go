@@ -0,0 +1,174 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultCoalesceHeaders are part of the identity of coalesced requests, so responses are never shared between different representations.
+// The credential headers are always part of it, see coalescer.key.
+var defaultCoalesceHeaders = []string{HeaderAccept, HeaderAcceptLanguage, HeaderAcceptEncoding}
+
+// maxCoalescedBodySize is the largest body shared between coalesced requests, when the request has no smaller MaxResponseBodySize.
+const maxCoalescedBodySize = 1 << 20
+
+// coalescer shares one upstream call between concurrent identical GET and HEAD requests.
+type coalescer struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done   chan struct{}
+	resp   *http.Response
+	body   []byte
+	shared bool
+	err    error
+}
+
+func newCoalescer(headers []string) *coalescer {
+	return &coalescer{
+		headers: headers,
+		calls:   make(map[string]*coalescedCall),
+	}
+}
+
+// roundTrip sends the request, or waits for the identical request in flight.
+// The response body is buffered up to the MaxResponseBodySize of the request or 1 MiB, every caller receives its own copy of the response.
+// A larger body is streamed to the first caller only, the others send their own request.
+// The call is made with the context of the first caller, the others stop waiting when their own context is done,
+// and make the call again if the first caller gave up while their own context is still alive.
+func (c *coalescer) roundTrip(req *http.Request, options *RequestOptions, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	// Range requests are used for large downloads, their bodies are not buffered.
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || (req.Body != nil && req.Body != http.NoBody) || req.Header.Get(HeaderRange) != "" {
+		return send(req)
+	}
+
+	key := c.key(req, options.redactor())
+
+	c.mu.Lock()
+	call, inFlight := c.calls[key]
+	if !inFlight {
+		call = &coalescedCall{done: make(chan struct{})}
+		c.calls[key] = call
+	}
+	c.mu.Unlock()
+
+	if !inFlight {
+		maxSize := int64(maxCoalescedBodySize)
+		if limit := options.MaxResponseBodySize; limit > 0 && limit < maxSize {
+			maxSize = limit
+		}
+		call.resp, call.body, call.shared, call.err = c.call(req, maxSize, send)
+
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+
+		if call.err != nil {
+			return nil, call.err
+		}
+		if !call.shared {
+			return call.resp, nil
+		}
+	}
+
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	if call.err != nil {
+		if isContextError(call.err) && req.Context().Err() == nil {
+			return c.roundTrip(req, options, send)
+		}
+		return nil, call.err
+	}
+	if !call.shared {
+		return send(req)
+	}
+
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(call.body))
+	resp.Request = req
+
+	return &resp, nil
+}
+
+// call sends the request and buffers the response body. If the body is larger than maxSize,
+// the response is returned with the streamed body and shared is false.
+func (c *coalescer) call(req *http.Request, maxSize int64, send func(req *http.Request) (*http.Response, error)) (resp *http.Response, body []byte, shared bool, err error) {
+	resp, err = send(req)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if resp.Body == nil {
+		return resp, nil, true, nil
+	}
+
+	// Bodies known to be too large are not buffered at all.
+	if resp.ContentLength > maxSize {
+		return resp, nil, false, nil
+	}
+
+	body, err = io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, nil, false, err
+	}
+	if int64(len(body)) > maxSize {
+		// The already read part is served along with the rest of the body.
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil, false, nil
+	}
+	_ = resp.Body.Close()
+
+	return resp, body, true, nil
+}
+
+// key identifies the request by the method, the URL, the configured headers and every credential header,
+// including the headers masked by the redactor, e.g. the header of SetAPIKey.
+func (c *coalescer) key(req *http.Request, redactor *Redactor) string {
+	names := append(append([]string{}, c.headers...), credentialHeaders...)
+	if redactor != nil {
+		for name := range redactor.headers {
+			names = append(names, name)
+		}
+	}
+	for i, name := range names {
+		names[i] = http.CanonicalHeaderKey(name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(req.Method)
+	key.WriteByte(' ')
+	key.WriteString(req.URL.String())
+
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		key.WriteByte('\n')
+		key.WriteString(name)
+		key.WriteByte(':')
+		key.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+
+	return key.String()
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
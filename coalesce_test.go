@@ -0,0 +1,243 @@
+package httpreqx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	r := require.New(t)
+
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set(HeaderContentType, "application/json")
+		w.Write([]byte(`{"user":"` + req.Header.Get(HeaderAuthorization) + `"}`))
+	}))
+	defer server.Close()
+
+	client := NewHttpClient().
+		SetBodyUnmarshaler(NewJSONBodyUnmarshaler()).
+		SetCoalesce(true)
+	ctx := context.Background()
+
+	type result struct {
+		User string `json:"user"`
+	}
+
+	var wg sync.WaitGroup
+	results := make([]result, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			auth := "a"
+			if i%2 == 1 {
+				auth = "b"
+			}
+			_, err := client.NewGetRequest(ctx, server.URL+"/config").
+				SetHeader(HeaderAuthorization, auth).
+				WriteBodyTo(&results[i]).
+				Do()
+			r.NoError(err)
+		}(i)
+	}
+
+	// Give every request the chance to join the call in flight before the server responds.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	r.Equal(int32(2), atomic.LoadInt32(&hits), "one upstream call per Authorization header")
+	for i, res := range results {
+		if i%2 == 0 {
+			r.Equal("a", res.User)
+		} else {
+			r.Equal("b", res.User)
+		}
+	}
+
+	t.Run("Caller context", func(t *testing.T) {
+		block := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-block
+		}))
+		defer slow.Close()
+		defer close(block)
+
+		go client.NewGetRequest(ctx, slow.URL).Do()
+		time.Sleep(20 * time.Millisecond)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := client.NewGetRequest(timeoutCtx, slow.URL).Do()
+		r.ErrorIs(err, context.DeadlineExceeded)
+	})
+
+	t.Run("Cookies are part of the identity", func(t *testing.T) {
+		var hits int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			<-release
+			w.Write([]byte(req.Header.Get(HeaderCookie)))
+		}))
+		defer server.Close()
+
+		client := NewHttpClient().SetCoalesce(true)
+
+		var wg sync.WaitGroup
+		results := make([]string, 4)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := client.NewGetRequest(ctx, server.URL).
+					SetHeader(HeaderCookie, fmt.Sprintf("session=%d", i%2)).
+					WriteBodyTo(&results[i]).
+					Do()
+				r.NoError(err)
+			}(i)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		r.Equal(int32(2), atomic.LoadInt32(&hits))
+		for i, result := range results {
+			r.Equal(fmt.Sprintf("session=%d", i%2), result)
+		}
+	})
+
+	t.Run("Bodies over the limit are not shared", func(t *testing.T) {
+		var hits int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			<-release
+			w.Write([]byte(strings.Repeat("x", 100)))
+		}))
+		defer server.Close()
+
+		client := NewHttpClient().SetCoalesce(true).SetMaxResponseBodySize(10)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var result string
+				_, err := client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+				r.ErrorIs(err, ErrBodyTooLarge)
+			}()
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		r.Equal(int32(3), atomic.LoadInt32(&hits), "the waiting requests send their own request")
+	})
+
+	t.Run("Large bodies are not shared without a limit", func(t *testing.T) {
+		var hits int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			<-release
+			w.Write([]byte(strings.Repeat("x", maxCoalescedBodySize+1)))
+		}))
+		defer server.Close()
+
+		client := NewHttpClient().SetCoalesce(true)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var result []byte
+				_, err := client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+				r.NoError(err)
+				r.Len(result, maxCoalescedBodySize+1)
+			}()
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		r.Equal(int32(3), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Waiting requests make the call again when the first one is canceled", func(t *testing.T) {
+		var hits int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			select {
+			case <-release:
+				w.Write([]byte("ok"))
+			case <-req.Context().Done():
+			}
+		}))
+		defer server.Close()
+
+		client := NewHttpClient().SetCoalesce(true)
+
+		leaderCtx, cancel := context.WithCancel(ctx)
+		leaderErr := make(chan error, 1)
+		go func() {
+			_, err := client.NewGetRequest(leaderCtx, server.URL).Do()
+			leaderErr <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		followerErr := make(chan error, 1)
+		var result string
+		go func() {
+			_, err := client.NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+			followerErr <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		r.ErrorIs(<-leaderErr, context.Canceled)
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		r.NoError(<-followerErr)
+		r.Equal("ok", result)
+		r.Equal(int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Non-idempotent requests are not coalesced", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.NewPostRequest(ctx, server.URL+"/config", nil).Do()
+				r.NoError(err)
+			}()
+		}
+		wg.Wait()
+
+		r.Equal(int32(3), atomic.LoadInt32(&hits))
+	})
+}
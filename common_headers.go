@@ -5,7 +5,9 @@ const (
 	HeaderAcceptEncoding     = "Accept-Encoding"
 	HeaderAcceptLanguage     = "Accept-Language"
 	HeaderAuthorization      = "Authorization"
+	HeaderWWWAuthenticate    = "WWW-Authenticate"
 	HeaderCacheControl       = "Cache-Control"
+	HeaderContentEncoding    = "Content-Encoding"
 	HeaderContentLength      = "Content-Length"
 	HeaderContentType        = "Content-Type"
 	HeaderCookie             = "Cookie"
@@ -18,8 +20,23 @@ const (
 	HeaderETag               = "ETag"
 	HeaderIfModifiedSince    = "If-Modified-Since"
 	HeaderIfNoneMatch        = "If-None-Match"
+	HeaderLastModified       = "Last-Modified"
+	HeaderExpires            = "Expires"
+	HeaderAge                = "Age"
+	HeaderVary               = "Vary"
+	HeaderDate               = "Date"
+	HeaderXFromCache         = "X-From-Cache"
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderLink               = "Link"
+	HeaderRange              = "Range"
+	HeaderIfRange            = "If-Range"
+	HeaderContentRange       = "Content-Range"
+	HeaderAcceptRanges       = "Accept-Ranges"
+	HeaderContentMD5         = "Content-MD5"
 	HeaderXRequestedWith     = "X-Requested-With"
 	HeaderXForwardedFor      = "X-Forwarded-For"
 	HeaderXFrameOptions      = "X-Frame-Options"
 	HeaderStrictTransportSec = "Strict-Transport-Security"
+	HeaderTraceParent        = "Traceparent"
+	HeaderTraceState         = "Tracestate"
 )
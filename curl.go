@@ -0,0 +1,196 @@
+package httpreqx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ToCurl builds the request exactly as Do would (marshaled body, headers, before request hooks, credentials of the Authenticator
+// and the signature of the Signer) and renders it as a curl command.
+// Sensitive data is masked with the configured Redactor, so a command built with redaction enabled might need the secrets to be filled in before running it.
+// Note that the OnRequestReady hooks are executed and the Authenticator might fetch a token as a part of building the request.
+func (r *Request) ToCurl() (string, error) {
+	req, _, err := r.prepareRequest(r.ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return curlCommand(req, r.options.redactor()), nil
+}
+
+// curlCommand renders the http.Request as a copy-pastable curl command.
+// The body is re-read via GetBody, so the request can still be sent afterward.
+func curlCommand(req *http.Request, redactor *Redactor) string {
+	if req == nil {
+		return ""
+	}
+
+	var body []byte
+	if req.GetBody != nil {
+		if bodyReader, err := req.GetBody(); err == nil && bodyReader != nil {
+			body, _ = io.ReadAll(bodyReader)
+			_ = bodyReader.Close()
+		}
+	}
+
+	args := []string{"curl"}
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X", req.Method)
+	}
+
+	args = append(args, shellQuote(redactor.RedactURL(req.URL)))
+
+	contentType := req.Header.Get(HeaderContentType)
+	mediaType, mediaParams, _ := mime.ParseMediaType(contentType)
+	isMultipart := strings.HasPrefix(mediaType, "multipart/") && mediaParams["boundary"] != "" && len(body) > 0
+
+	if acceptsCompressed(req.Header.Get(HeaderAcceptEncoding)) {
+		// curl sends its own Accept-Encoding header and decompresses the response when this flag is set.
+		args = append(args, "--compressed")
+	}
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote(HeaderHost+": "+req.Host))
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch http.CanonicalHeaderKey(name) {
+		case HeaderContentLength:
+			// Calculated by curl.
+			continue
+		case HeaderAcceptEncoding:
+			if acceptsCompressed(req.Header.Get(HeaderAcceptEncoding)) {
+				continue
+			}
+		case HeaderContentType:
+			if isMultipart {
+				// curl generates its own boundary for the -F parts.
+				continue
+			}
+		}
+
+		for _, value := range req.Header[name] {
+			args = append(args, "-H", shellQuote(name+": "+redactor.RedactHeaderValue(name, value)))
+		}
+	}
+
+	if len(body) == 0 {
+		return strings.Join(args, " ")
+	}
+
+	if isMultipart {
+		if formArgs, err := curlMultipartArgs(body, mediaParams["boundary"], redactor); err == nil {
+			return strings.Join(append(args, formArgs...), " ")
+		}
+	}
+
+	body = redactor.RedactBody(contentType, body)
+
+	if req.Header.Get(HeaderContentEncoding) != "" || !isPrintable(body) {
+		// Compressed and binary bodies can not be passed as an argument, so they are piped via printf.
+		return fmt.Sprintf("printf %s | %s --data-binary @-", shellQuote(printfEscape(body)), strings.Join(args, " "))
+	}
+
+	return strings.Join(append(args, "--data-binary", shellQuote(string(body))), " ")
+}
+
+// curlMultipartArgs renders every part of the multipart body as a curl -F argument.
+// File contents are not inlined, the file name is referenced instead and must be present on the machine running the command.
+func curlMultipartArgs(body []byte, boundary string, redactor *Redactor) ([]string, error) {
+	var args []string
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return args, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := part.FormName()
+		if fileName := part.FileName(); fileName != "" {
+			value := fmt.Sprintf("%s=@%s", name, fileName)
+			if partType := part.Header.Get(HeaderContentType); partType != "" {
+				value += ";type=" + partType
+			}
+			args = append(args, "-F", shellQuote(value))
+			continue
+		}
+
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, "--form-string", shellQuote(name+"="+redactor.redactFieldValue(name, string(value))))
+	}
+}
+
+func acceptsCompressed(acceptEncoding string) bool {
+	for _, encoding := range strings.Split(acceptEncoding, ",") {
+		encoding, _, _ = strings.Cut(strings.TrimSpace(encoding), ";")
+		switch strings.ToLower(encoding) {
+		case "gzip", "deflate", "br", "zstd":
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPrintable(body []byte) bool {
+	if !utf8.Valid(body) {
+		return false
+	}
+
+	for _, r := range string(body) {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shellQuote wraps the value in single quotes, which disables all interpretation in POSIX shells.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// printfEscape renders the body as a printf format string, using octal escapes for everything but plain ASCII.
+func printfEscape(body []byte) string {
+	var builder strings.Builder
+	for _, b := range body {
+		switch {
+		case b == '%':
+			builder.WriteString("%%")
+		case b == '\\':
+			builder.WriteString(`\\`)
+		case b >= 0x20 && b < 0x7f:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, `\%03o`, b)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,117 @@
+package httpreqx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToCurl(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	t.Run("GET with redacted headers and query", func(t *testing.T) {
+		client := NewHttpClient().
+			SetHeader(HeaderAuthorization, "Bearer secret").
+			SetHeader(HeaderAcceptEncoding, "gzip, br")
+
+		command, err := client.NewGetRequest(ctx, "https://example.com/users?api_key=secret&page=1").
+			SetHeader("X-Request-Id", "it's-1").
+			ToCurl()
+
+		r.NoError(err)
+		r.Equal(`curl 'https://example.com/users?api_key=%5BREDACTED%5D&page=1' --compressed -H 'Authorization: [REDACTED]' -H 'X-Request-Id: it'\''s-1'`, command)
+	})
+
+	t.Run("Credentials and signature", func(t *testing.T) {
+		signer := NewHMACSigner([]byte("secret")).SetTimestampHeader("").SetNonceHeader("")
+		client := NewHttpClient().SetBearerToken("secret").SetSigner(signer)
+
+		command, err := client.NewGetRequest(ctx, "https://example.com/users").ToCurl()
+		r.NoError(err)
+
+		canonical, err := NewCanonicalRequestBuilder().Build(httptest.NewRequest(http.MethodGet, "https://example.com/users", nil), []byte{})
+		r.NoError(err)
+		r.Equal(`curl 'https://example.com/users' -H 'Authorization: [REDACTED]' -H 'X-Signature: `+signer.signatureOf(canonical)+`'`, command)
+
+		command, err = client.NewGetRequest(ctx, "https://example.com/users").SetRedactor(nil).ToCurl()
+		r.NoError(err)
+		r.Contains(command, `-H 'Authorization: Bearer secret'`)
+	})
+
+	t.Run("POST with JSON body", func(t *testing.T) {
+		client := NewHttpClient().SetBodyMarshaler(NewJSONBodyMarshaler())
+
+		req := client.NewPostRequest(ctx, "https://example.com/login", map[string]string{"user": "john", "password": "p"})
+		command, err := req.ToCurl()
+
+		r.NoError(err)
+		r.Equal(`curl -X POST 'https://example.com/login' -H 'Content-Type: application/json' --data-binary '{"password":"[REDACTED]","user":"john"}'`, command)
+
+		// The marshaled body is cached, so the request can be rendered again with the same result.
+		again, err := req.ToCurl()
+		r.NoError(err)
+		r.Equal(command, again)
+	})
+
+	t.Run("Compressed body", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		writer := gzip.NewWriter(buf)
+		_, err := writer.Write([]byte("hello"))
+		r.NoError(err)
+		r.NoError(writer.Close())
+
+		command, err := NewHttpClient().NewPostRequest(ctx, "https://example.com/upload", buf.Bytes()).
+			SetHeader(HeaderContentEncoding, "gzip").
+			ToCurl()
+
+		r.NoError(err)
+		r.Regexp(`^printf '\\037\\213.*' \| curl -X POST 'https://example.com/upload' -H 'Content-Encoding: gzip' --data-binary @-$`, command)
+	})
+
+	t.Run("Multipart body", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		writer := multipart.NewWriter(buf)
+		r.NoError(writer.WriteField("name", "report"))
+		r.NoError(writer.WriteField("token", "secret"))
+		part, err := writer.CreateFormFile("file", "report.csv")
+		r.NoError(err)
+		_, err = part.Write([]byte("a,b"))
+		r.NoError(err)
+		r.NoError(writer.Close())
+
+		command, err := NewHttpClient().NewPostRequest(ctx, "https://example.com/files", buf.Bytes()).
+			SetHeader(HeaderContentType, writer.FormDataContentType()).
+			ToCurl()
+
+		r.NoError(err)
+		r.Equal(`curl -X POST 'https://example.com/files' --form-string 'name=report' --form-string 'token=[REDACTED]' -F 'file=@report.csv;type=application/octet-stream'`, command)
+	})
+
+	t.Run("Curl on error prints the error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		output := captureStdout(t, func() {
+			_, err := NewHttpClient().NewGetRequest(ctx, server.URL+"/users?api_key=secret").SetCurlOnError().Do()
+			r.Error(err)
+		})
+
+		r.Contains(output, "ERROR: 500 Internal Server Error:500")
+		r.Contains(output, "Request as curl:\ncurl '"+server.URL+"/users?api_key=%5BREDACTED%5D'")
+		r.NotContains(output, "secret")
+	})
+
+	t.Run("Marshaling error", func(t *testing.T) {
+		_, err := NewHttpClient().NewPostRequest(ctx, "https://example.com", 42).ToCurl()
+		r.ErrorContains(err, "body marshaling")
+	})
+}
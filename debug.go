@@ -1,6 +1,8 @@
 package httpreqx
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +11,9 @@ import (
 	"strings"
 )
 
+// maxDumpedBodySize limits the number of response body bytes printed by the dumps.
+const maxDumpedBodySize = 64 << 10
+
 type EnrichedError struct {
 	Err   error
 	Stack string
@@ -30,12 +35,16 @@ func (e *EnrichedError) Error() string {
 	return fmt.Sprintf("%s\nStack trace:\n%s", e.Err.Error(), e.Stack)
 }
 
+func (e *EnrichedError) Unwrap() error {
+	return e.Err
+}
+
 func enrichErrorWithStackTrace(err error) error {
 	stacktrace := string(debug.Stack())
 	return &EnrichedError{err, stacktrace}
 }
 
-func dumpError(err error) {
+func dumpError(err error, redactor *Redactor) {
 	if err == nil {
 		return
 	}
@@ -45,16 +54,16 @@ func dumpError(err error) {
 		err = enrichErrorWithStackTrace(err)
 	}
 
-	fmt.Printf("ERROR: %s\n", err)
+	fmt.Printf("ERROR: %s\n", redactor.RedactError(err))
 }
 
-func dumpRequest(req *http.Request) {
+func dumpRequest(req *http.Request, redactor *Redactor) {
 	if req == nil {
 		return
 	}
 
 	var headers []string
-	for name, values := range req.Header {
+	for name, values := range redactor.RedactHeaders(req.Header) {
 		for _, value := range values {
 			headers = append(headers, fmt.Sprintf("%s: %s", name, value))
 		}
@@ -65,7 +74,7 @@ func dumpRequest(req *http.Request) {
 	bodyReader, _ := req.GetBody()
 	if bodyReader != nil {
 		bodyBytes, _ := io.ReadAll(bodyReader)
-		body = string(bodyBytes)
+		body = string(redactor.RedactBody(req.Header.Get(HeaderContentType), bodyBytes))
 	}
 
 	if body != "" {
@@ -74,34 +83,50 @@ func dumpRequest(req *http.Request) {
 		body = "<empty>"
 	}
 
-	fmt.Printf("Request: %s %s\n", req.Method, req.URL.String())
+	fmt.Printf("Request: %s %s\n", req.Method, redactor.RedactURL(req.URL))
 	fmt.Printf("Request headers:\n%s\n", strings.Join(headers, "\n"))
 	fmt.Printf("Request body: %v\n", body)
 }
 
-func dumpResponse(resp *http.Response) {
+func dumpResponse(resp *http.Response, redactor *Redactor) {
 	if resp == nil {
 		return
 	}
 
 	var headers []string
-	for name, values := range resp.Header {
+	for name, values := range redactor.RedactHeaders(resp.Header) {
 		for _, value := range values {
 			headers = append(headers, fmt.Sprintf("%s: %s", name, value))
 		}
 	}
 
 	var body string
+	truncated := false
 	if resp.Body != nil {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		_ = resp.Body.Close()
-		resp.Body = io.NopCloser(strings.NewReader(string(bodyBytes))) // Reset body for further use
-		body = string(bodyBytes)
+		// Only the beginning of large bodies is printed, the rest is kept in the body for further use.
+		bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxDumpedBodySize+1))
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(bodyBytes), resp.Body), Closer: resp.Body}
+
+		truncated = len(bodyBytes) > maxDumpedBodySize || errors.Is(err, ErrBodyTooLarge)
+		if len(bodyBytes) > maxDumpedBodySize {
+			bodyBytes = bodyBytes[:maxDumpedBodySize]
+		}
+
+		trimmed := bytes.TrimSpace(bodyBytes)
+		if truncated && redactor != nil && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			// A part of a JSON document can not be parsed, so it can not be masked either.
+			body = fmt.Sprintf("<%d bytes of JSON, not printed as it can not be redacted>", len(bodyBytes))
+		} else {
+			body = string(redactor.RedactBody(resp.Header.Get(HeaderContentType), bodyBytes))
+		}
 	}
 
 	if body == "" {
 		body = "<empty>"
 	}
+	if truncated {
+		body += " <truncated>"
+	}
 
 	fmt.Printf("Response status: %s\n", resp.Status)
 	fmt.Printf("Response status code: %d\n", resp.StatusCode)
@@ -109,7 +134,7 @@ func dumpResponse(resp *http.Response) {
 	fmt.Printf("Response body: %s\n", body)
 }
 
-func dumpBody(body interface{}) {
+func dumpBody(body interface{}, redactor *Redactor) {
 	if body == nil {
 		fmt.Println("Original body: <nil>")
 		return
@@ -118,11 +143,20 @@ func dumpBody(body interface{}) {
 	var normalizedBody string
 	switch v := body.(type) {
 	case string:
-		normalizedBody = v
+		normalizedBody = string(redactor.RedactBody("", []byte(v)))
 	case []byte:
-		normalizedBody = string(v)
+		normalizedBody = string(redactor.RedactBody("", v))
+	case io.Reader:
+		// Readers are consumed by the marshaler, there is nothing left to print.
+		normalizedBody = fmt.Sprintf("<%T>", v)
 	default:
 		normalizedBody = fmt.Sprintf("%v", v)
+		if redactor != nil {
+			// Field names are lost in the %v representation, so structured values are printed as JSON to be able to mask them.
+			if jsonBody, err := json.Marshal(v); err == nil {
+				normalizedBody = string(redactor.RedactBody("", jsonBody))
+			}
+		}
 	}
 
 	fmt.Printf("Original body: %s\n", normalizedBody)
@@ -0,0 +1,333 @@
+package httpreqx
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestAlgorithms are the supported algorithms, in the order of preference when the server offers several challenges.
+var digestAlgorithms = []string{"SHA-256", "SHA-256-SESS", "MD5", "MD5-SESS"}
+
+// DigestAuth is an Authenticator implementing HTTP Digest authentication (RFC 7616) with the MD5 and SHA-256 algorithms (and their -sess variants)
+// and the "auth" and "auth-int" qop values.
+// The first request to a host is sent without credentials, the 401 challenge is answered by repeating the request with the buffered body.
+// The challenge is cached per realm, later requests to the same host are authenticated right away with an incremented nonce count,
+// until the server rejects the nonce as stale.
+type DigestAuth struct {
+	username string
+	password string
+	cnonce   func() (string, error)
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge
+	hostRealms map[string]string
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+	nc        uint32
+}
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// NewDigestAuth creates a DigestAuth authenticator.
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{
+		username:   username,
+		password:   password,
+		cnonce:     randomNonce,
+		challenges: make(map[string]*digestChallenge),
+		hostRealms: make(map[string]string),
+	}
+}
+
+func (a *DigestAuth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	challenge, ok := a.challenges[a.hostRealms[req.URL.Host]]
+	if !ok {
+		a.mu.Unlock()
+		return nil
+	}
+	challenge.nc++
+	current := *challenge
+	a.mu.Unlock()
+
+	authorization, err := a.authorization(req, &current)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderAuthorization, authorization)
+	return nil
+}
+
+func (a *DigestAuth) HandleUnauthorized(req *http.Request, resp *http.Response) bool {
+	challenge := selectDigestChallenge(resp.Header.Values(HeaderWWWAuthenticate))
+	if challenge == nil {
+		return false
+	}
+
+	// The credentials were rejected with a valid nonce, repeating the request won't help.
+	sentNonce := parseAuthChallenges(req.Header.Get(HeaderAuthorization))
+	if len(sentNonce) > 0 && strings.EqualFold(sentNonce[0].scheme, "Digest") &&
+		sentNonce[0].params["nonce"] == challenge.nonce {
+		return false
+	}
+
+	a.mu.Lock()
+	a.challenges[challenge.realm] = challenge
+	a.hostRealms[req.URL.Host] = challenge.realm
+	a.mu.Unlock()
+
+	return true
+}
+
+func (a *DigestAuth) authorization(req *http.Request, challenge *digestChallenge) (string, error) {
+	newHash := sha256.New
+	if strings.HasPrefix(challenge.algorithm, "MD5") {
+		newHash = md5.New
+	}
+	h := func(data string) string {
+		return hashHex(newHash, []byte(data))
+	}
+
+	cnonce, err := a.cnonce()
+	if err != nil {
+		return "", fmt.Errorf("digest cnonce: %w", err)
+	}
+	nc := fmt.Sprintf("%08x", challenge.nc)
+	uri := req.URL.RequestURI()
+
+	qop, err := digestQop(req, challenge.qop)
+	if err != nil {
+		return "", err
+	}
+
+	ha1 := h(a.username + ":" + challenge.realm + ":" + a.password)
+	if strings.HasSuffix(challenge.algorithm, "-sess") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(req.Method + ":" + uri)
+	if qop == "auth-int" {
+		body, err := digestBody(req)
+		if err != nil {
+			return "", err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + hashHex(newHash, body))
+	}
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, challenge.nonce, nc, cnonce, qop, ha2}, ":"))
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%s", quoteAuthParam(a.username)),
+		fmt.Sprintf("realm=%s", quoteAuthParam(challenge.realm)),
+		fmt.Sprintf("nonce=%s", quoteAuthParam(challenge.nonce)),
+		fmt.Sprintf("uri=%s", quoteAuthParam(uri)),
+		fmt.Sprintf("algorithm=%s", challenge.algorithm),
+		fmt.Sprintf("response=%s", quoteAuthParam(response)),
+	}
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%s", quoteAuthParam(challenge.opaque)))
+	}
+	if qop != "" {
+		params = append(params, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%s", quoteAuthParam(cnonce)))
+	}
+
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+// digestQop selects "auth" when offered, "auth-int" otherwise. An empty result means the legacy RFC 2069 mode without qop.
+func digestQop(req *http.Request, offered []string) (string, error) {
+	if len(offered) == 0 {
+		return "", nil
+	}
+
+	for _, qop := range offered {
+		if qop == "auth" {
+			return qop, nil
+		}
+	}
+	for _, qop := range offered {
+		if qop == "auth-int" {
+			return qop, nil
+		}
+	}
+
+	return "", fmt.Errorf("digest: unsupported qop %q", strings.Join(offered, ","))
+}
+
+// digestBody returns the buffered body of the request for the auth-int qop, without consuming req.Body.
+func digestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte{}, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("digest: auth-int requires a buffered body")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("digest: %w", err)
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// selectDigestChallenge returns the Digest challenge with the most preferred supported algorithm, or nil if there is none.
+func selectDigestChallenge(headers []string) *digestChallenge {
+	var selected *digestChallenge
+	selectedRank := len(digestAlgorithms)
+
+	for _, header := range headers {
+		for _, challenge := range parseAuthChallenges(header) {
+			if !strings.EqualFold(challenge.scheme, "Digest") || challenge.params["nonce"] == "" {
+				continue
+			}
+
+			algorithm := strings.ToUpper(challenge.params["algorithm"])
+			if algorithm == "" {
+				algorithm = "MD5"
+			}
+
+			rank := -1
+			for i, supported := range digestAlgorithms {
+				if algorithm == supported {
+					rank = i
+				}
+			}
+			if rank < 0 || rank >= selectedRank {
+				continue
+			}
+
+			var qop []string
+			for _, value := range strings.Split(challenge.params["qop"], ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					qop = append(qop, strings.ToLower(value))
+				}
+			}
+
+			selectedRank = rank
+			selected = &digestChallenge{
+				realm:     challenge.params["realm"],
+				nonce:     challenge.params["nonce"],
+				opaque:    challenge.params["opaque"],
+				algorithm: algorithm,
+				qop:       qop,
+			}
+		}
+	}
+
+	if selected != nil && strings.HasSuffix(selected.algorithm, "-SESS") {
+		// The algorithm is sent back in the spelling used by RFC 7616, e.g. "MD5-sess".
+		selected.algorithm = strings.TrimSuffix(selected.algorithm, "-SESS") + "-sess"
+	}
+
+	return selected
+}
+
+// parseAuthChallenges parses the challenges of a WWW-Authenticate header (or the credentials of an Authorization header),
+// e.g. `Digest realm="api", nonce="abc", Basic realm="api"`. Parameter names are lowercase.
+func parseAuthChallenges(header string) []authChallenge {
+	var challenges []authChallenge
+
+	i := 0
+	skip := func(chars string) {
+		for i < len(header) && strings.IndexByte(chars, header[i]) >= 0 {
+			i++
+		}
+	}
+	readToken := func() string {
+		start := i
+		for i < len(header) && strings.IndexByte(" \t,=\"", header[i]) < 0 {
+			i++
+		}
+		return header[start:i]
+	}
+
+	for {
+		skip(" \t,")
+		if i >= len(header) {
+			return challenges
+		}
+
+		token := readToken()
+		if token == "" {
+			i++
+			continue
+		}
+
+		skip(" \t")
+		if i >= len(header) || header[i] != '=' {
+			challenges = append(challenges, authChallenge{scheme: token, params: make(map[string]string)})
+			continue
+		}
+
+		i++
+		skip(" \t")
+		var value string
+		if i < len(header) && header[i] == '"' {
+			value = readQuotedString(header, &i)
+		} else {
+			value = readToken()
+			// token68 values (e.g. base64 with padding) are not parameters, the padding is skipped.
+			skip("=")
+		}
+
+		if len(challenges) > 0 {
+			challenges[len(challenges)-1].params[strings.ToLower(token)] = value
+		}
+	}
+}
+
+// readQuotedString reads the quoted string starting at *i and moves *i past the closing quote.
+func readQuotedString(s string, i *int) string {
+	var value strings.Builder
+	for *i++; *i < len(s); *i++ {
+		switch s[*i] {
+		case '\\':
+			if *i+1 < len(s) {
+				*i++
+				value.WriteByte(s[*i])
+			}
+		case '"':
+			*i++
+			return value.String()
+		default:
+			value.WriteByte(s[*i])
+		}
+	}
+
+	return value.String()
+}
+
+func quoteAuthParam(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func hashHex(newHash func() hash.Hash, data []byte) string {
+	h := newHash()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
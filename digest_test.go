@@ -0,0 +1,142 @@
+package httpreqx
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestAuth(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("RFC 7616 test vectors", func(t *testing.T) {
+		for algorithm, expected := range map[string]string{
+			"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+			"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		} {
+			auth := NewDigestAuth("Mufasa", "Circle of Life")
+			auth.cnonce = func() (string, error) { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", nil }
+
+			req, err := http.NewRequest(http.MethodGet, "http://www.example.org/dir/index.html", nil)
+			r.NoError(err)
+
+			resp := &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{}}
+			resp.Header.Add(HeaderWWWAuthenticate, `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=`+algorithm+`, `+
+				`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+			r.True(auth.HandleUnauthorized(req, resp))
+			r.NoError(auth.Authenticate(req))
+
+			params := parseAuthChallenges(req.Header.Get(HeaderAuthorization))[0].params
+			r.Equal(expected, params["response"], algorithm)
+			r.Equal("00000001", params["nc"])
+			r.Equal("auth", params["qop"])
+			r.Equal("/dir/index.html", params["uri"])
+			r.Equal("FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", params["opaque"])
+		}
+	})
+
+	t.Run("Parses multiple challenges", func(t *testing.T) {
+		challenge := selectDigestChallenge([]string{
+			`Basic realm="api", Digest realm="api", nonce="n1", algorithm=MD5`,
+			`Digest realm="api", nonce="n2", algorithm=SHA-256, qop="auth"`,
+			`Digest realm="api", nonce="n3", algorithm=SHA-512-256`,
+		})
+		r.NotNil(challenge)
+		r.Equal("n2", challenge.nonce)
+		r.Equal("SHA-256", challenge.algorithm)
+
+		r.Nil(selectDigestChallenge([]string{`Bearer realm="api"`}))
+	})
+
+	t.Run("Challenge round trip with cached nonce and auth-int", func(t *testing.T) {
+		var mu sync.Mutex
+		nonce := "nonce-1"
+		var requests, unauthorized int
+		var lastNC, lastBody string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests++
+
+			body, _ := io.ReadAll(req.Body)
+			challenges := parseAuthChallenges(req.Header.Get(HeaderAuthorization))
+			stale := len(challenges) > 0 && challenges[0].params["nonce"] != nonce
+			if len(challenges) == 0 || stale || !validDigestResponse(challenges[0].params, req.Method, "secret", body) {
+				unauthorized++
+				w.Header().Set(HeaderWWWAuthenticate, fmt.Sprintf(`Digest realm="appliance", nonce=%q, algorithm=MD5-sess, qop="auth-int", stale=%t`, nonce, stale))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			lastNC, lastBody = challenges[0].params["nc"], string(body)
+		}))
+		defer server.Close()
+
+		client := NewHttpClient().
+			SetBodyMarshaler(NewJSONBodyMarshaler()).
+			SetAuth(NewDigestAuth("admin", "secret"))
+		ctx := context.Background()
+
+		_, err := client.NewPostRequest(ctx, server.URL+"/config?x=1", map[string]int{"port": 80}).Do()
+		r.NoError(err)
+		r.Equal(2, requests, "the body is replayed after the challenge")
+		r.Equal("00000001", lastNC)
+		r.Equal(`{"port":80}`, strings.TrimSpace(lastBody))
+
+		_, err = client.NewPostRequest(ctx, server.URL+"/config", map[string]int{"port": 81}).Do()
+		r.NoError(err)
+		r.Equal(3, requests, "the cached nonce is used right away")
+		r.Equal("00000002", lastNC)
+
+		mu.Lock()
+		nonce = "nonce-2"
+		mu.Unlock()
+		_, err = client.NewGetRequest(ctx, server.URL+"/status").Do()
+		r.NoError(err)
+		r.Equal(5, requests, "a stale nonce is replaced")
+		r.Equal("00000001", lastNC)
+		r.Equal(2, unauthorized)
+	})
+
+	t.Run("Wrong password is not retried forever", func(t *testing.T) {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			w.Header().Set(HeaderWWWAuthenticate, `Digest realm="appliance", nonce="fixed", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		auth := NewDigestAuth("admin", "wrong")
+		client := NewHttpClient().SetAuth(auth)
+		ctx := context.Background()
+
+		_, err := client.NewGetRequest(ctx, server.URL).Do()
+		r.Error(err)
+		r.Equal(2, requests)
+
+		_, err = client.NewGetRequest(ctx, server.URL).Do()
+		r.Error(err)
+		r.Equal(3, requests, "the rejected nonce is not retried")
+	})
+}
+
+// validDigestResponse verifies the MD5-sess/auth-int response of the test server.
+func validDigestResponse(params map[string]string, method, password string, body []byte) bool {
+	h := func(data string) string { return fmt.Sprintf("%x", md5.Sum([]byte(data))) }
+
+	ha1 := h(h(params["username"]+":"+params["realm"]+":"+password) + ":" + params["nonce"] + ":" + params["cnonce"])
+	ha2 := h(method + ":" + params["uri"] + ":" + fmt.Sprintf("%x", md5.Sum(body)))
+	expected := h(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+
+	return params["algorithm"] == "MD5-sess" && params["qop"] == "auth-int" && params["response"] == expected
+}
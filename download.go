@@ -0,0 +1,522 @@
+package httpreqx
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDownloadMaxResumes = 5
+	downloadBufferSize        = 32 * 1024
+	// downloadValidatorSuffix names the file next to a file destination which keeps the If-Range validator of the content,
+	// so a download interrupted by a restart of the program is resumed from the size of the file.
+	downloadValidatorSuffix = ".validator"
+)
+
+// ErrChecksumMismatch is returned when the downloaded content does not match the expected SHA-256 or the Content-MD5 checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// DownloadProgressHook receives the number of bytes received so far and the total size, -1 if the size is unknown.
+// For a Download the received count goes back to 0 if the download is restarted because the resource has changed.
+type DownloadProgressHook func(received, total int64)
+
+// DownloadResult describes the completed download.
+type DownloadResult struct {
+	Size int64
+	ETag string
+	// SHA256 is the hex encoded SHA-256 checksum of the content.
+	SHA256 string
+	// Resumes is the number of times the download was continued after an interruption.
+	Resumes int
+}
+
+// Download fetches a resource into an io.WriterAt or a file, resuming with Range requests after interruptions.
+// Resumed requests carry the If-Range header with the ETag (or Last-Modified date) of the first response,
+// so the download is restarted from zero instead of mixing the content of two versions of the resource.
+// For a file path the validator is kept in a file with the ".validator" suffix until the download is completed,
+// the next download into the same path continues from the size of the file if the resource has not changed.
+// The requests are made with the client, so its headers, authentication, retry policy and other options apply.
+type Download struct {
+	client           *HttpClient
+	ctx              context.Context
+	url              string
+	dest             interface{}
+	expectedSHA256   string
+	verifyContentMD5 bool
+	maxResumes       int
+	onProgress       DownloadProgressHook
+	segments         int
+}
+
+// NewDownload creates a Download of the url into dest, which is either an io.WriterAt (e.g. *os.File) or the path of a file.
+// The file is created if it does not exist, the content of an existing file is resumed or overwritten.
+func (c *HttpClient) NewDownload(ctx context.Context, url string, dest interface{}) *Download {
+	return &Download{
+		client:     c,
+		ctx:        ctx,
+		url:        url,
+		dest:       dest,
+		maxResumes: defaultDownloadMaxResumes,
+	}
+}
+
+// Download fetches the url into dest, an io.WriterAt or the path of a file, resuming after interruptions.
+// Use NewDownload to verify checksums or report progress.
+func (c *HttpClient) Download(ctx context.Context, url string, dest interface{}) (*DownloadResult, error) {
+	return c.NewDownload(ctx, url, dest).Do()
+}
+
+// SetSHA256 sets the expected hex encoded SHA-256 checksum of the content, ErrChecksumMismatch is returned if it differs.
+func (d *Download) SetSHA256(checksum string) *Download {
+	d.expectedSHA256 = strings.ToLower(checksum)
+	return d
+}
+
+// SetVerifyContentMD5 enables verifying the content against the Content-MD5 header of the full response, if the server sends it.
+func (d *Download) SetVerifyContentMD5(enabled bool) *Download {
+	d.verifyContentMD5 = enabled
+	return d
+}
+
+// SetMaxResumes sets how many times in a row the download is resumed without receiving any data, 5 by default.
+func (d *Download) SetMaxResumes(maxResumes int) *Download {
+	d.maxResumes = maxResumes
+	return d
+}
+
+// SetSegments enables the segmented mode: the content is fetched in the number of byte ranges concurrently, each of them resumed independently.
+// The size of the content is requested first, the download falls back to a single stream if the server does not support ranges.
+// Verifying the SHA-256 checksum in the segmented mode requires the destination to be an io.ReaderAt (e.g. a file), the content is read back after the download.
+// The Content-MD5 header is not verified in the segmented mode.
+func (d *Download) SetSegments(segments int) *Download {
+	d.segments = segments
+	return d
+}
+
+// SetOnProgress sets the hook called after every chunk written to the destination. The calls are never concurrent.
+func (d *Download) SetOnProgress(hook DownloadProgressHook) *Download {
+	d.onProgress = hook
+	return d
+}
+
+// downloadState is the progress of a download, kept between the resumed requests.
+type downloadState struct {
+	writer     io.WriterAt
+	offset     int64
+	total      int64
+	etag       string
+	validator  string
+	contentMD5 string
+	sha256     hash.Hash
+	md5        hash.Hash
+}
+
+func (s *downloadState) restart() {
+	s.offset = 0
+	s.total = -1
+	s.etag = ""
+	s.validator = ""
+	s.contentMD5 = ""
+	s.sha256.Reset()
+	s.md5.Reset()
+}
+
+// Do runs the download.
+func (d *Download) Do() (*DownloadResult, error) {
+	writer, closeWriter, err := openDownloadDest(d.dest)
+	if err != nil {
+		return nil, fmt.Errorf("download destination: %w", err)
+	}
+	defer closeWriter()
+
+	state, err := d.resumeState(writer)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := d.run(state)
+	if result != nil {
+		// The content is complete, the next download into the path starts from zero.
+		d.forgetValidator()
+	}
+
+	return result, err
+}
+
+func (d *Download) run(state *downloadState) (*DownloadResult, error) {
+	// A file left by an interrupted download is continued by a single stream.
+	if d.segments > 1 && state.offset == 0 {
+		result, err := d.segmented(state.writer)
+		if !errors.Is(err, errSegmentsNotSupported) {
+			return result, err
+		}
+	}
+
+	return d.stream(state)
+}
+
+// resumeState returns the state of the download, continuing from the size of the destination file if its validator was kept.
+func (d *Download) resumeState(writer io.WriterAt) (*downloadState, error) {
+	state := &downloadState{writer: writer, total: -1, sha256: sha256.New(), md5: md5.New()}
+
+	path, ok := d.dest.(string)
+	if !ok {
+		return state, nil
+	}
+
+	validator, err := os.ReadFile(path + downloadValidatorSuffix)
+	if err != nil || len(validator) == 0 {
+		return state, nil
+	}
+
+	file := writer.(*os.File)
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("download destination: %w", err)
+	}
+
+	// The checksum covers the whole content, the part written by the interrupted download is read back.
+	// Content-MD5 is not verified, it is sent only with the full content.
+	if _, err := io.Copy(state.sha256, io.NewSectionReader(file, 0, info.Size())); err != nil {
+		return nil, fmt.Errorf("download destination: %w", err)
+	}
+
+	state.offset = info.Size()
+	state.validator = string(validator)
+	// The validator is either the strong ETag or the Last-Modified date.
+	if strings.HasPrefix(state.validator, `"`) {
+		state.etag = state.validator
+	}
+
+	return state, nil
+}
+
+// stream downloads the content sequentially, resuming from the current offset after interruptions.
+func (d *Download) stream(state *downloadState) (*DownloadResult, error) {
+	resumes := 0
+	stalled := 0
+
+	for {
+		received, complete, err := d.fetch(state)
+		if complete {
+			break
+		}
+
+		// Only interruptions of the transfer are resumed, the errors of the request itself and the canceled context are final.
+		if !isResumableDownloadError(err) || d.ctx.Err() != nil {
+			return nil, err
+		}
+
+		if received > 0 {
+			stalled = 0
+		} else {
+			stalled++
+		}
+		if stalled > d.maxResumes {
+			return nil, fmt.Errorf("download interrupted: %w", err)
+		}
+		resumes++
+	}
+
+	if err := truncateDownloadDest(state.writer, state.offset); err != nil {
+		return nil, err
+	}
+
+	result := &DownloadResult{
+		Size:    state.offset,
+		ETag:    state.etag,
+		SHA256:  hex.EncodeToString(state.sha256.Sum(nil)),
+		Resumes: resumes,
+	}
+
+	if err := d.verifySHA256(result); err != nil {
+		return result, err
+	}
+	if d.verifyContentMD5 && state.contentMD5 != "" {
+		if actual := base64.StdEncoding.EncodeToString(state.md5.Sum(nil)); actual != state.contentMD5 {
+			return result, fmt.Errorf("%w: Content-MD5 is %s, expected %s", ErrChecksumMismatch, actual, state.contentMD5)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Download) verifySHA256(result *DownloadResult) error {
+	if d.expectedSHA256 != "" && result.SHA256 != d.expectedSHA256 {
+		return fmt.Errorf("%w: sha256 is %s, expected %s", ErrChecksumMismatch, result.SHA256, d.expectedSHA256)
+	}
+
+	return nil
+}
+
+// truncateDownloadDest cuts the destination to the size of the content, if it supports it, e.g. a file which was longer before.
+func truncateDownloadDest(writer io.WriterAt, size int64) error {
+	truncater, ok := writer.(interface{ Truncate(size int64) error })
+	if !ok {
+		return nil
+	}
+
+	if err := truncater.Truncate(size); err != nil {
+		return fmt.Errorf("download destination: %w", err)
+	}
+
+	return nil
+}
+
+// rangeRequest creates the request for the range, with the If-Range header if the validator is not empty.
+func (d *Download) rangeRequest(ctx context.Context, byteRange, validator string) *Request {
+	request := d.client.NewGetRequest(ctx, d.url).
+		SetHeader(HeaderRange, byteRange).
+		// Compressed responses can not be resumed, the ranges would refer to the encoded content.
+		SetHeader(HeaderAcceptEncoding, "identity").
+		// The content is streamed to the destination, the limit for the bodies kept in memory does not apply.
+		SetMaxResponseBodySize(0)
+	if validator != "" {
+		request.SetHeader(HeaderIfRange, validator)
+	}
+
+	return request
+}
+
+// fetch requests the rest of the content from the current offset and writes it to the destination.
+// It returns the number of bytes written and whether the download is complete.
+func (d *Download) fetch(state *downloadState) (int64, bool, error) {
+	validator := ""
+	if state.offset > 0 {
+		validator = state.validator
+	}
+
+	resp, err := d.rangeRequest(d.ctx, "bytes="+strconv.FormatInt(state.offset, 10)+"-", validator).Do()
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// The content ends exactly at the offset, e.g. an empty resource.
+			discardBody(resp)
+			_, _, total, ok := parseContentRange(resp.Header.Get(HeaderContentRange))
+			if ok && total == state.offset {
+				state.total = total
+				return 0, true, nil
+			}
+			if ok && state.offset > 0 {
+				// The resumed file is longer than the content.
+				state.restart()
+				return 0, false, errDownloadRestarted
+			}
+		}
+		discardBody(resp)
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, end, total, ok := parseContentRange(resp.Header.Get(HeaderContentRange))
+		if !ok {
+			return 0, false, fmt.Errorf("download: invalid %s header %q", HeaderContentRange, resp.Header.Get(HeaderContentRange))
+		}
+
+		etag := resp.Header.Get(HeaderETag)
+		if start != state.offset || (state.etag != "" && etag != "" && etag != state.etag) {
+			// The server ignored the validator, the content is requested again from the start.
+			state.restart()
+			return 0, false, errDownloadRestarted
+		}
+
+		state.total = total
+		if state.offset == 0 {
+			d.remember(state, resp)
+			// Content-MD5 of a partial response covers only the part, it is used if the part is the whole content.
+			if end == total-1 {
+				state.contentMD5 = resp.Header.Get(HeaderContentMD5)
+			}
+		}
+	case http.StatusOK:
+		// The server does not support ranges or the resource has changed since the first request.
+		state.restart()
+		state.total = resp.ContentLength
+		d.remember(state, resp)
+		state.contentMD5 = resp.Header.Get(HeaderContentMD5)
+	default:
+		return 0, false, fmt.Errorf("download: unexpected status %s", resp.Status)
+	}
+
+	received, err := d.copy(state, resp.Body)
+	if err != nil {
+		return received, false, err
+	}
+	if state.total >= 0 && state.offset < state.total {
+		return received, false, io.ErrUnexpectedEOF
+	}
+
+	return received, true, nil
+}
+
+// remember keeps the validator of the first response, it is sent in the If-Range header of the resumed requests.
+// For a file destination it is written next to the file as well.
+func (d *Download) remember(state *downloadState, resp *http.Response) {
+	state.etag = resp.Header.Get(HeaderETag)
+	state.validator = rangeValidator(resp)
+
+	path, ok := d.dest.(string)
+	if !ok {
+		return
+	}
+	if state.validator == "" {
+		d.forgetValidator()
+		return
+	}
+	// A validator which can not be written only prevents resuming by the next download, the current one continues.
+	_ = os.WriteFile(path+downloadValidatorSuffix, []byte(state.validator), 0o644)
+}
+
+// forgetValidator removes the validator kept next to a file destination.
+func (d *Download) forgetValidator() {
+	if path, ok := d.dest.(string); ok {
+		_ = os.Remove(path + downloadValidatorSuffix)
+	}
+}
+
+// rangeValidator returns the value for the If-Range header: the strong ETag or the Last-Modified date of the response.
+func rangeValidator(resp *http.Response) string {
+	// Weak ETags can not be used with If-Range.
+	if etag := resp.Header.Get(HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get(HeaderLastModified)
+}
+
+// copy writes the body to the destination at the current offset.
+func (d *Download) copy(state *downloadState, body io.Reader) (int64, error) {
+	return copyAt(state.writer, state.offset, body, func(chunk []byte) {
+		state.sha256.Write(chunk)
+		state.md5.Write(chunk)
+		state.offset += int64(len(chunk))
+
+		if d.onProgress != nil {
+			d.onProgress(state.offset, state.total)
+		}
+	})
+}
+
+// copyAt writes the body to the writer starting at the offset, onChunk is called after every written chunk.
+// Write errors are wrapped into downloadWriteError.
+func copyAt(writer io.WriterAt, offset int64, body io.Reader, onChunk func(chunk []byte)) (int64, error) {
+	var written int64
+	buf := make([]byte, downloadBufferSize)
+
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := writer.WriteAt(buf[:n], offset+written); err != nil {
+				return written, &downloadWriteError{err: err}
+			}
+			written += int64(n)
+			onChunk(buf[:n])
+		}
+
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
+
+// errDownloadRestarted is returned by fetch when the content has changed and is downloaded again.
+var errDownloadRestarted = errors.New("download restarted")
+
+type downloadWriteError struct {
+	err error
+}
+
+func (e *downloadWriteError) Error() string {
+	return "download destination: " + e.err.Error()
+}
+
+func (e *downloadWriteError) Unwrap() error {
+	return e.err
+}
+
+// isResumableDownloadError reports whether the download can be continued after the error.
+func isResumableDownloadError(err error) bool {
+	var writeErr *downloadWriteError
+	if errors.As(err, &writeErr) {
+		return false
+	}
+
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.Phase == ErrorPhaseTransport
+	}
+
+	return err != nil
+}
+
+// parseContentRange parses the "bytes start-end/total" and "bytes */total" values, total is -1 if it is unknown.
+func parseContentRange(value string) (start, end, total int64, ok bool) {
+	rangeSpec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	positions, size, found := strings.Cut(rangeSpec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total < 0 {
+			return 0, 0, 0, false
+		}
+	}
+
+	if positions == "*" {
+		return 0, -1, total, total >= 0
+	}
+
+	first, last, found := strings.Cut(positions, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	var err error
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+		return 0, 0, 0, false
+	}
+
+	return start, end, total, true
+}
+
+// openDownloadDest returns the writer for the destination and the function releasing it.
+func openDownloadDest(dest interface{}) (io.WriterAt, func(), error) {
+	switch v := dest.(type) {
+	case string:
+		// The file is not truncated, its content is resumed or cut to the size of the downloaded content at the end.
+		file, err := os.OpenFile(v, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, func() { _ = file.Close() }, nil
+	case io.WriterAt:
+		return v, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported destination type %T, expected io.WriterAt or file path", dest)
+	}
+}
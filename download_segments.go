@@ -0,0 +1,201 @@
+package httpreqx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// errSegmentsNotSupported is returned by the segmented mode when the download must fall back to a single stream.
+var errSegmentsNotSupported = errors.New("segmented download is not supported")
+
+// downloadSegment is the inclusive byte range [start, end] of the content, offset is the next byte to be fetched.
+type downloadSegment struct {
+	start  int64
+	end    int64
+	offset int64
+}
+
+// segmented probes the size of the content with the "Range: bytes=0-0" request and fetches the segments concurrently.
+// It returns errSegmentsNotSupported if the server does not support ranges or the content changes during the download.
+func (d *Download) segmented(writer io.WriterAt) (*DownloadResult, error) {
+	resp, err := d.rangeRequest(d.ctx, "bytes=0-0", "").Do()
+	if err != nil {
+		discardBody(resp)
+		if resp != nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return nil, errSegmentsNotSupported
+		}
+		return nil, err
+	}
+	discardBody(resp)
+
+	_, _, size, ok := parseContentRange(resp.Header.Get(HeaderContentRange))
+	if resp.StatusCode != http.StatusPartialContent || !ok || size <= 0 {
+		return nil, errSegmentsNotSupported
+	}
+
+	etag := resp.Header.Get(HeaderETag)
+	validator := rangeValidator(resp)
+	segments := splitDownloadSegments(size, d.segments)
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var received int64
+	var resumes int
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for _, segment := range segments {
+		wg.Add(1)
+		go func(segment *downloadSegment) {
+			defer wg.Done()
+
+			segmentResumes, err := d.fetchSegment(ctx, writer, segment, etag, validator, func(n int) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				received += int64(n)
+				if d.onProgress != nil {
+					d.onProgress(received, size)
+				}
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			resumes += segmentResumes
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		if errors.Is(firstErr, errSegmentsNotSupported) && d.ctx.Err() == nil {
+			return nil, errSegmentsNotSupported
+		}
+		return nil, firstErr
+	}
+
+	if err := truncateDownloadDest(writer, size); err != nil {
+		return nil, err
+	}
+
+	result := &DownloadResult{Size: size, ETag: etag, Resumes: resumes}
+
+	// The segments are written out of order, the checksum is calculated from the reassembled content.
+	if reader, ok := writer.(io.ReaderAt); ok {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(reader, 0, size)); err != nil {
+			return nil, fmt.Errorf("download destination: %w", err)
+		}
+		result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	} else if d.expectedSHA256 != "" {
+		return result, fmt.Errorf("download destination: %T is not an io.ReaderAt, the checksum of a segmented download can not be verified", writer)
+	}
+
+	if err := d.verifySHA256(result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// fetchSegment fetches the segment, resuming it after interruptions, and returns the number of resumes.
+func (d *Download) fetchSegment(
+	ctx context.Context,
+	writer io.WriterAt,
+	segment *downloadSegment,
+	etag, validator string,
+	onChunk func(n int),
+) (int, error) {
+	resumes := 0
+	stalled := 0
+
+	for {
+		received, err := d.fetchSegmentRange(ctx, writer, segment, etag, validator, onChunk)
+		if err == nil && segment.offset > segment.end {
+			return resumes, nil
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if !isResumableDownloadError(err) || errors.Is(err, errSegmentsNotSupported) || ctx.Err() != nil {
+			return resumes, err
+		}
+
+		if received > 0 {
+			stalled = 0
+		} else {
+			stalled++
+		}
+		if stalled > d.maxResumes {
+			return resumes, fmt.Errorf("download interrupted: %w", err)
+		}
+		resumes++
+	}
+}
+
+// fetchSegmentRange requests the rest of the segment and writes it to the destination.
+func (d *Download) fetchSegmentRange(
+	ctx context.Context,
+	writer io.WriterAt,
+	segment *downloadSegment,
+	etag, validator string,
+	onChunk func(n int),
+) (int64, error) {
+	byteRange := "bytes=" + strconv.FormatInt(segment.offset, 10) + "-" + strconv.FormatInt(segment.end, 10)
+	resp, err := d.rangeRequest(ctx, byteRange, validator).Do()
+	if err != nil {
+		discardBody(resp)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// A full response or a different ETag means the content has changed since the probe.
+	start, _, _, ok := parseContentRange(resp.Header.Get(HeaderContentRange))
+	if resp.StatusCode != http.StatusPartialContent || !ok || start != segment.offset {
+		return 0, errSegmentsNotSupported
+	}
+	if responseETag := resp.Header.Get(HeaderETag); etag != "" && responseETag != "" && responseETag != etag {
+		return 0, errSegmentsNotSupported
+	}
+
+	// Servers may send more than requested, the rest of the body is ignored.
+	remaining := segment.end - segment.offset + 1
+	return copyAt(writer, segment.offset, io.LimitReader(resp.Body, remaining), func(chunk []byte) {
+		segment.offset += int64(len(chunk))
+		onChunk(len(chunk))
+	})
+}
+
+// splitDownloadSegments splits the content into at most count segments of a similar size.
+func splitDownloadSegments(size int64, count int) []*downloadSegment {
+	if int64(count) > size {
+		count = int(size)
+	}
+
+	segments := make([]*downloadSegment, 0, count)
+	segmentSize := size / int64(count)
+	for i := 0; i < count; i++ {
+		start := int64(i) * segmentSize
+		end := start + segmentSize - 1
+		if i == count-1 {
+			end = size - 1
+		}
+		segments = append(segments, &downloadSegment{start: start, end: end, offset: start})
+	}
+
+	return segments
+}
@@ -0,0 +1,200 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentedDownload(t *testing.T) {
+	r := require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024+3)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var mu sync.Mutex
+	var ranges []string
+	interrupted := map[string]bool{}
+	changeAfterProbe := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		byteRange := req.Header.Get(HeaderRange)
+
+		mu.Lock()
+		ranges = append(ranges, byteRange)
+		// The end of the range identifies the segment, it stays the same when the segment is resumed
+		segmentEnd := byteRange[strings.Index(byteRange, "-")+1:]
+		interrupt := !interrupted[segmentEnd] && byteRange != "bytes=0-0" && req.URL.Path == "/flaky"
+		interrupted[segmentEnd] = true
+		changed := changeAfterProbe && byteRange != "bytes=0-0"
+		mu.Unlock()
+
+		switch {
+		case req.URL.Path == "/no-ranges":
+			_, _ = w.Write(content)
+			return
+		case changed:
+			w.Header().Set(HeaderETag, `"v2"`)
+		default:
+			w.Header().Set(HeaderETag, `"v1"`)
+		}
+
+		if interrupt {
+			// The segment is cut after a few bytes, the request of the rest is not interrupted again
+			w = &interruptingWriter{ResponseWriter: w, limit: 1000}
+			defer func() {
+				if w.(*interruptingWriter).cut {
+					panic(http.ErrAbortHandler)
+				}
+			}()
+		}
+
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+	ctx := context.Background()
+
+	reset := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		ranges = nil
+		interrupted = map[string]bool{}
+		changeAfterProbe = false
+	}
+	requested := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+
+	t.Run("Segments are fetched and reassembled", func(t *testing.T) {
+		reset()
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		var lastReceived, lastTotal int64
+		result, err := client.NewDownload(ctx, server.URL+"/file", path).
+			SetSegments(4).
+			SetSHA256(checksum).
+			SetOnProgress(func(received, total int64) { lastReceived, lastTotal = received, total }).
+			Do()
+		r.NoError(err)
+		r.Equal(int64(len(content)), result.Size)
+		r.Equal(checksum, result.SHA256)
+		r.Equal(`"v1"`, result.ETag)
+		r.Equal(int64(len(content)), lastReceived)
+		r.Equal(int64(len(content)), lastTotal)
+
+		data, err := os.ReadFile(path)
+		r.NoError(err)
+		r.Equal(content, data)
+
+		sent := requested()
+		r.Len(sent, 5)
+		r.Equal("bytes=0-0", sent[0])
+		r.ElementsMatch([]string{"bytes=0-262155", "bytes=262156-524311", "bytes=524312-786467", "bytes=786468-1048623"}, sent[1:])
+	})
+
+	t.Run("Interrupted segments are resumed independently", func(t *testing.T) {
+		reset()
+
+		dest := &writerAtBuffer{}
+		result, err := client.NewDownload(ctx, server.URL+"/flaky", dest).SetSegments(3).Do()
+		r.NoError(err)
+		r.Equal(content, dest.Bytes())
+		r.Equal(3, result.Resumes)
+		// The checksum can not be calculated without reading the destination back
+		r.Empty(result.SHA256)
+
+		// Every segment is requested once more from the offset where it was cut
+		sent := requested()
+		r.Len(sent, 7)
+		for _, segment := range splitDownloadSegments(int64(len(content)), 3) {
+			r.Contains(sent, fmt.Sprintf("bytes=%d-%d", segment.start, segment.end))
+			r.Contains(sent, fmt.Sprintf("bytes=%d-%d", segment.start+1000, segment.end))
+		}
+	})
+
+	t.Run("Server without range support falls back to a single stream", func(t *testing.T) {
+		reset()
+
+		dest := &writerAtBuffer{}
+		result, err := client.NewDownload(ctx, server.URL+"/no-ranges", dest).SetSegments(4).Do()
+		r.NoError(err)
+		r.Equal(content, dest.Bytes())
+		r.Equal(checksum, result.SHA256)
+		r.Equal([]string{"bytes=0-0", "bytes=0-"}, requested())
+	})
+
+	t.Run("Changed content falls back to a single stream", func(t *testing.T) {
+		reset()
+		mu.Lock()
+		changeAfterProbe = true
+		mu.Unlock()
+
+		dest := &writerAtBuffer{}
+		result, err := client.NewDownload(ctx, server.URL+"/file", dest).SetSegments(2).Do()
+		r.NoError(err)
+		r.Equal(content, dest.Bytes())
+		r.Equal(`"v2"`, result.ETag)
+	})
+
+	t.Run("Checksum requires a readable destination", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewDownload(ctx, server.URL+"/file", &writerAtBuffer{}).SetSegments(2).SetSHA256(checksum).Do()
+		r.ErrorContains(err, "not an io.ReaderAt")
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		_, err = client.NewDownload(ctx, server.URL+"/file", path).SetSegments(2).SetSHA256(hex.EncodeToString(make([]byte, 32))).Do()
+		r.ErrorIs(err, ErrChecksumMismatch)
+	})
+}
+
+func TestSplitDownloadSegments(t *testing.T) {
+	r := require.New(t)
+
+	segments := splitDownloadSegments(10, 3)
+	r.Len(segments, 3)
+	r.Equal([]int64{0, 2}, []int64{segments[0].start, segments[0].end})
+	r.Equal([]int64{3, 5}, []int64{segments[1].start, segments[1].end})
+	r.Equal([]int64{6, 9}, []int64{segments[2].start, segments[2].end})
+
+	r.Len(splitDownloadSegments(2, 5), 2)
+}
+
+// interruptingWriter stops writing the body after the limit.
+type interruptingWriter struct {
+	http.ResponseWriter
+	limit int
+	cut   bool
+}
+
+func (w *interruptingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		p = p[:w.limit]
+		w.cut = true
+	}
+	w.limit -= len(p)
+
+	n, err := w.ResponseWriter.Write(p)
+	if err == nil && w.cut {
+		w.ResponseWriter.(http.Flusher).Flush()
+		return n, http.ErrAbortHandler
+	}
+	return n, err
+}
@@ -0,0 +1,310 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writerAtBuffer is an in-memory io.WriterAt.
+type writerAtBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if end := int(off) + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	copy(b.data[off:], p)
+	return len(p), nil
+}
+
+func (b *writerAtBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.data...)
+}
+
+func TestDownload(t *testing.T) {
+	r := require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	changed := bytes.Repeat([]byte("fedcba9876543210"), 64*1024)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var mu sync.Mutex
+	var requests []*http.Request
+	interruptions := 0
+	current := content
+	etag := `"v1"`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests = append(requests, req)
+		interrupt := interruptions > 0
+		if interrupt {
+			interruptions--
+		}
+		body, tag := current, etag
+		mu.Unlock()
+
+		if interrupt {
+			// Sends a part of the content and drops the connection
+			from := int64(0)
+			if req.Header.Get(HeaderRange) != "" && req.URL.Path != "/no-ranges" {
+				from, _ = strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(req.Header.Get(HeaderRange), "bytes="), "-"), 10, 64)
+				w.Header().Set(HeaderContentRange, "bytes "+strconv.FormatInt(from, 10)+"-"+strconv.Itoa(len(body)-1)+"/"+strconv.Itoa(len(body)))
+				w.Header().Set(HeaderETag, tag)
+				w.Header().Set(HeaderContentLength, strconv.FormatInt(int64(len(body))-from, 10))
+				w.WriteHeader(http.StatusPartialContent)
+			} else {
+				w.Header().Set(HeaderContentLength, strconv.Itoa(len(body)))
+				w.WriteHeader(http.StatusOK)
+			}
+			_, _ = w.Write(body[from : from+int64(len(body)-int(from))/3])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		switch req.URL.Path {
+		case "/no-ranges":
+			_, _ = w.Write(body)
+		case "/md5":
+			digest := md5.Sum([]byte("other"))
+			w.Header().Set(HeaderContentMD5, base64.StdEncoding.EncodeToString(digest[:]))
+			_, _ = w.Write(body)
+		case "/empty":
+			http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(nil))
+		default:
+			w.Header().Set(HeaderETag, tag)
+			http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(body))
+		}
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+	ctx := context.Background()
+
+	reset := func(interrupt int) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = nil
+		interruptions = interrupt
+		current = content
+		etag = `"v1"`
+	}
+	sent := func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+
+	t.Run("Interrupted download is resumed", func(t *testing.T) {
+		reset(2)
+
+		dest := &writerAtBuffer{}
+		var lastReceived, lastTotal int64
+		result, err := client.NewDownload(ctx, server.URL+"/file", dest).
+			SetSHA256(checksum).
+			SetOnProgress(func(received, total int64) {
+				r.GreaterOrEqual(received, lastReceived)
+				lastReceived, lastTotal = received, total
+			}).
+			Do()
+		r.NoError(err)
+		r.Equal(content, dest.Bytes())
+		r.Equal(int64(len(content)), result.Size)
+		r.Equal(checksum, result.SHA256)
+		r.Equal(`"v1"`, result.ETag)
+		r.Equal(2, result.Resumes)
+		r.Equal(int64(len(content)), lastReceived)
+		r.Equal(int64(len(content)), lastTotal)
+
+		requests := sent()
+		r.Len(requests, 3)
+		r.Equal("bytes=0-", requests[0].Header.Get(HeaderRange))
+		r.Empty(requests[0].Header.Get(HeaderIfRange))
+		r.NotEqual("bytes=0-", requests[1].Header.Get(HeaderRange))
+		r.Equal(`"v1"`, requests[1].Header.Get(HeaderIfRange))
+		r.Equal("identity", requests[1].Header.Get(HeaderAcceptEncoding))
+	})
+
+	t.Run("Changed resource restarts the download", func(t *testing.T) {
+		reset(1)
+
+		dest := &writerAtBuffer{}
+		_, err := client.NewDownload(ctx, server.URL+"/file", dest).
+			SetOnProgress(func(received, total int64) {
+				// The resource changes after the first part was received
+				mu.Lock()
+				current, etag = changed, `"v2"`
+				mu.Unlock()
+			}).
+			Do()
+		r.NoError(err)
+		r.Equal(changed, dest.Bytes())
+	})
+
+	t.Run("Server without range support", func(t *testing.T) {
+		reset(1)
+
+		dest := &writerAtBuffer{}
+		result, err := client.Download(ctx, server.URL+"/no-ranges", dest)
+		r.NoError(err)
+		r.Equal(content, dest.Bytes())
+		r.Equal(1, result.Resumes)
+	})
+
+	t.Run("File destination is truncated", func(t *testing.T) {
+		reset(1)
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		r.NoError(os.WriteFile(path, bytes.Repeat([]byte("x"), len(content)*2), 0o644))
+
+		_, err := client.Download(ctx, server.URL+"/file", path)
+		r.NoError(err)
+
+		data, err := os.ReadFile(path)
+		r.NoError(err)
+		r.Equal(content, data)
+		r.NoFileExists(path + downloadValidatorSuffix)
+	})
+
+	t.Run("File of an interrupted download is resumed by the next download", func(t *testing.T) {
+		reset(1)
+
+		// The program is stopped after the connection was dropped
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		canceledCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		_, err := client.NewDownload(canceledCtx, server.URL+"/file", path).
+			SetOnProgress(func(received, total int64) { cancel() }).
+			Do()
+		r.Error(err)
+
+		info, err := os.Stat(path)
+		r.NoError(err)
+		r.Greater(info.Size(), int64(0))
+		r.Less(info.Size(), int64(len(content)))
+		validator, err := os.ReadFile(path + downloadValidatorSuffix)
+		r.NoError(err)
+		r.Equal(`"v1"`, string(validator))
+
+		reset(0)
+		result, err := client.NewDownload(ctx, server.URL+"/file", path).SetSHA256(checksum).Do()
+		r.NoError(err)
+		r.Equal(checksum, result.SHA256)
+		r.Equal(`"v1"`, result.ETag)
+
+		requests := sent()
+		r.Len(requests, 1)
+		r.Equal("bytes="+strconv.FormatInt(info.Size(), 10)+"-", requests[0].Header.Get(HeaderRange))
+		r.Equal(`"v1"`, requests[0].Header.Get(HeaderIfRange))
+
+		data, err := os.ReadFile(path)
+		r.NoError(err)
+		r.Equal(content, data)
+		r.NoFileExists(path + downloadValidatorSuffix)
+	})
+
+	t.Run("File of a changed resource is downloaded again", func(t *testing.T) {
+		reset(0)
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		r.NoError(os.WriteFile(path, changed[:100], 0o644))
+		r.NoError(os.WriteFile(path+downloadValidatorSuffix, []byte(`"v2"`), 0o644))
+
+		result, err := client.NewDownload(ctx, server.URL+"/file", path).SetSHA256(checksum).Do()
+		r.NoError(err)
+		r.Equal(`"v1"`, result.ETag)
+
+		requests := sent()
+		r.Equal("bytes=100-", requests[0].Header.Get(HeaderRange))
+		r.Equal(`"v2"`, requests[0].Header.Get(HeaderIfRange))
+
+		data, err := os.ReadFile(path)
+		r.NoError(err)
+		r.Equal(content, data)
+		r.NoFileExists(path + downloadValidatorSuffix)
+	})
+
+	t.Run("Empty resource", func(t *testing.T) {
+		reset(0)
+
+		dest := &writerAtBuffer{}
+		result, err := client.Download(ctx, server.URL+"/empty", dest)
+		r.NoError(err)
+		r.Zero(result.Size)
+		r.Empty(dest.Bytes())
+	})
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		reset(0)
+
+		_, err := client.NewDownload(ctx, server.URL+"/file", &writerAtBuffer{}).SetSHA256(hex.EncodeToString(make([]byte, 32))).Do()
+		r.ErrorIs(err, ErrChecksumMismatch)
+
+		_, err = client.NewDownload(ctx, server.URL+"/md5", &writerAtBuffer{}).SetVerifyContentMD5(true).Do()
+		r.ErrorIs(err, ErrChecksumMismatch)
+	})
+
+	t.Run("Status errors are not resumed", func(t *testing.T) {
+		reset(0)
+
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer failing.Close()
+
+		_, err := client.Download(ctx, failing.URL, &writerAtBuffer{})
+
+		var requestErr *RequestError
+		r.ErrorAs(err, &requestErr)
+		r.Equal(http.StatusNotFound, requestErr.StatusCode)
+	})
+
+	t.Run("Unsupported destination", func(t *testing.T) {
+		_, err := client.Download(ctx, server.URL+"/file", &bytes.Buffer{})
+		r.ErrorContains(err, "unsupported destination type")
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	r := require.New(t)
+
+	start, end, total, ok := parseContentRange("bytes 10-19/100")
+	r.True(ok)
+	r.Equal([]int64{10, 19, 100}, []int64{start, end, total})
+
+	_, _, total, ok = parseContentRange("bytes 0-9/*")
+	r.True(ok)
+	r.Equal(int64(-1), total)
+
+	_, _, total, ok = parseContentRange("bytes */0")
+	r.True(ok)
+	r.Zero(total)
+
+	for _, value := range []string{"", "bytes 5-1/10", "items 0-1/2", "bytes 0-1"} {
+		_, _, _, ok = parseContentRange(value)
+		r.False(ok, value)
+	}
+}
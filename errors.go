@@ -0,0 +1,46 @@
+package httpreqx
+
+// ErrorPhase identifies the step of Request.Do in which an error occurred.
+type ErrorPhase string
+
+const (
+	// ErrorPhaseRejected - the request was rejected before it was started, e.g. by an open circuit breaker.
+	ErrorPhaseRejected ErrorPhase = "rejected"
+	// ErrorPhaseMarshal - the body could not be marshaled.
+	ErrorPhaseMarshal ErrorPhase = "marshal"
+	// ErrorPhaseRequest - the http.Request could not be created or one of the before request hooks failed.
+	ErrorPhaseRequest ErrorPhase = "request"
+	// ErrorPhaseTransport - the request could not be sent or the response could not be received.
+	ErrorPhaseTransport ErrorPhase = "transport"
+	// ErrorPhaseResponse - one of the response hooks failed.
+	ErrorPhaseResponse ErrorPhase = "response"
+	// ErrorPhaseStatus - the response has a non-2xx status code.
+	ErrorPhaseStatus ErrorPhase = "status"
+	// ErrorPhaseUnmarshal - the response body could not be unmarshaled.
+	ErrorPhaseUnmarshal ErrorPhase = "unmarshal"
+)
+
+// RequestError is the error returned by Request.Do.
+// It keeps the message of the original error and wraps it, so errors.Is and errors.As can be used to inspect the cause.
+// Use errors.As to get it, as the error might be additionally wrapped into EnrichedError when stack traces are enabled.
+type RequestError struct {
+	Phase  ErrorPhase
+	Method string
+	// URL is masked with the Redactor of the request.
+	URL string
+	// StatusCode is 0 if no response was received.
+	StatusCode int
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	if e == nil || e.Err == nil {
+		return "<nil>"
+	}
+
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,418 @@
+package httpreqx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const defaultHARMaxBodySize = 1 << 20
+
+// HAR is the root of an HTTP Archive 1.2 document (see http://www.softwareishard.com/blog/har-12-spec/).
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings contains durations of the request phases in milliseconds. -1 is used for the blocked, dns, connect and ssl phases that do not apply,
+// the send, wait and receive phases are always set, 0 if they did not happen.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder records every request/response pair made by the HttpClient it is attached to and exports them as HAR 1.2 JSON.
+// The output can be opened in the browser devtools or any other HAR viewer.
+// Headers, cookies, URLs and bodies are masked with the Redactor of the request.
+// It is safe for concurrent use.
+type HARRecorder struct {
+	mu          sync.Mutex
+	entries     []*HAREntry
+	maxBodySize int64
+}
+
+// NewHARRecorder creates a HARRecorder that captures up to 1 MiB of every request and response body.
+// Attach it to a client with HttpClient.SetHARRecorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{maxBodySize: defaultHARMaxBodySize}
+}
+
+// SetMaxBodySize sets the maximum number of bytes captured of every request and response body.
+// Bodies exceeding the limit are truncated in the archive, the data received by the caller is not affected.
+// A truncated JSON body can not be redacted, it is replaced with a placeholder unless the Redactor is disabled.
+func (h *HARRecorder) SetMaxBodySize(size int64) *HARRecorder {
+	h.mu.Lock()
+	h.maxBodySize = size
+	h.mu.Unlock()
+	return h
+}
+
+// HAR returns a snapshot of the recorded entries as a HAR document.
+func (h *HARRecorder) HAR() *HAR {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := make([]*HAREntry, len(h.entries))
+	for i, entry := range h.entries {
+		entryCopy := *entry
+		entries[i] = &entryCopy
+	}
+
+	return &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "httpreqx", Version: "1"},
+			Entries: entries,
+		},
+	}
+}
+
+// WriteTo writes the recorded entries as HAR JSON to the writer.
+func (h *HARRecorder) WriteTo(writer io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(h.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := writer.Write(data)
+	return int64(n), err
+}
+
+// WriteFile writes the recorded entries as HAR JSON to the file, creating or truncating it.
+func (h *HARRecorder) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.WriteTo(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Reset removes all recorded entries.
+func (h *HARRecorder) Reset() {
+	h.mu.Lock()
+	h.entries = nil
+	h.mu.Unlock()
+}
+
+// record adds an entry for the request and, if the response is present, wraps its body to capture the content and the receive timing.
+// The entry is added before the body is consumed, so requests whose bodies are never read or closed are recorded as well.
+func (h *HARRecorder) record(req *http.Request, resp *http.Response, err error, trace *connTrace, redactor *Redactor) {
+	h.mu.Lock()
+	maxBodySize := h.maxBodySize
+	h.mu.Unlock()
+
+	entry := &HAREntry{
+		StartedDateTime: trace.snapshot().start,
+		Request:         harRequest(req, redactor, maxBodySize),
+		Response: HARResponse{
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+
+	if err != nil {
+		entry.Comment = redactor.RedactError(err).Error()
+	}
+
+	if resp != nil {
+		entry.Response = harResponse(resp, redactor)
+	}
+
+	h.mu.Lock()
+	entry.Timings, entry.Time = harTimings(trace.snapshot())
+	entry.ServerIPAddress = serverIP(trace.snapshot().remoteAddress)
+	h.entries = append(h.entries, entry)
+	h.mu.Unlock()
+
+	if resp == nil || resp.Body == nil {
+		return
+	}
+
+	var content []byte
+	var size int64
+	contentType := resp.Header.Get(HeaderContentType)
+
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		trace:      trace,
+		onRead: func(p []byte) {
+			size += int64(len(p))
+			if remaining := maxBodySize - int64(len(content)); remaining > 0 {
+				if int64(len(p)) > remaining {
+					p = p[:remaining]
+				}
+				content = append(content, p...)
+			}
+		},
+		onDone: func(err error) {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			truncated := size > int64(len(content))
+			entry.Response.Content = harContent(harBody(redactor, contentType, content, truncated), contentType, size)
+			entry.Response.BodySize = size
+			if truncated {
+				entry.Response.Content.Comment = "truncated"
+			}
+			if err != nil && entry.Comment == "" {
+				entry.Comment = err.Error()
+			}
+			entry.Timings, entry.Time = harTimings(trace.snapshot())
+		},
+	}
+}
+
+func harRequest(req *http.Request, redactor *Redactor, maxBodySize int64) HARRequest {
+	harReq := HARRequest{
+		Method:      req.Method,
+		URL:         redactor.RedactURL(req.URL),
+		HTTPVersion: req.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(req.Header, redactor),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+
+	for _, cookie := range req.Cookies() {
+		harReq.Cookies = append(harReq.Cookies, HARNameValue{
+			Name:  cookie.Name,
+			Value: redactor.RedactHeaderValue(HeaderCookie, cookie.Value),
+		})
+	}
+
+	if redactedURL, err := req.URL.Parse(harReq.URL); err == nil {
+		for name, values := range redactedURL.Query() {
+			for _, value := range values {
+				harReq.QueryString = append(harReq.QueryString, HARNameValue{Name: name, Value: value})
+			}
+		}
+	}
+
+	if req.GetBody != nil && req.ContentLength != 0 {
+		if bodyReader, err := req.GetBody(); err == nil && bodyReader != nil {
+			// One more byte tells whether the body is truncated, also when the length is unknown.
+			body, _ := io.ReadAll(io.LimitReader(bodyReader, maxBodySize+1))
+			_ = bodyReader.Close()
+
+			truncated := int64(len(body)) > maxBodySize || req.ContentLength > int64(len(body))
+			if int64(len(body)) > maxBodySize {
+				body = body[:maxBodySize]
+			}
+
+			contentType := req.Header.Get(HeaderContentType)
+			harReq.PostData = &HARPostData{
+				MimeType: contentType,
+				Text:     string(harBody(redactor, contentType, body, truncated)),
+			}
+			if truncated {
+				harReq.PostData.Comment = "truncated"
+			}
+		}
+	}
+
+	return harReq
+}
+
+func harResponse(resp *http.Response, redactor *Redactor) HARResponse {
+	harResp := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(resp.Header, redactor),
+		Content:     HARContent{Size: 0, MimeType: resp.Header.Get(HeaderContentType)},
+		RedirectURL: resp.Header.Get(HeaderLocation),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	// Status is in the "200 OK" form.
+	if _, statusText, found := strings.Cut(resp.Status, " "); found {
+		harResp.StatusText = statusText
+	}
+
+	for _, cookie := range resp.Cookies() {
+		harResp.Cookies = append(harResp.Cookies, HARNameValue{
+			Name:  cookie.Name,
+			Value: redactor.RedactHeaderValue(HeaderSetCookie, cookie.Value),
+		})
+	}
+
+	return harResp
+}
+
+func harHeaders(headers http.Header, redactor *Redactor) []HARNameValue {
+	result := []HARNameValue{}
+	for name, values := range redactor.RedactHeaders(headers) {
+		for _, value := range values {
+			result = append(result, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	return result
+}
+
+// harBody masks the captured body. A truncated JSON document can not be parsed, so it can not be masked either,
+// it is replaced with a placeholder unless redaction is disabled.
+func harBody(redactor *Redactor, contentType string, body []byte, truncated bool) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if truncated && redactor != nil && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return []byte(fmt.Sprintf("<%d bytes of JSON, omitted as it can not be redacted>", len(body)))
+	}
+
+	return redactor.RedactBody(contentType, body)
+}
+
+func harContent(body []byte, mimeType string, size int64) HARContent {
+	content := HARContent{Size: size, MimeType: mimeType}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	return content
+}
+
+func harTimings(times traceTimes) (HARTimings, float64) {
+	timings := HARTimings{
+		Blocked: -1,
+		DNS:     harDuration(times.dnsStart, times.dnsDone),
+		Connect: harDuration(times.connectStart, times.connectDone),
+		// HAR 1.2 allows -1 only for blocked, dns, connect and ssl, the missing send, wait and receive phases are 0.
+		Send:    nonNegative(harDuration(times.gotConn, times.wroteRequest)),
+		Wait:    nonNegative(harDuration(times.wroteRequest, times.firstByte)),
+		Receive: nonNegative(harDuration(times.firstByte, times.bodyDone)),
+		SSL:     harDuration(times.tlsStart, times.tlsDone),
+	}
+
+	// According to the spec the connect time includes the SSL handshake.
+	if timings.SSL >= 0 && !times.connectStart.IsZero() {
+		timings.Connect = harDuration(times.connectStart, times.tlsDone)
+	}
+
+	if blocked := harDuration(times.start, times.gotConn); blocked >= 0 {
+		timings.Blocked = blocked - nonNegative(timings.DNS) - nonNegative(timings.Connect)
+		if timings.Blocked < 0 {
+			timings.Blocked = 0
+		}
+	}
+
+	total := nonNegative(timings.Blocked) + nonNegative(timings.DNS) + nonNegative(timings.Connect) +
+		timings.Send + timings.Wait + timings.Receive
+
+	return timings, total
+}
+
+func harDuration(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return -1
+	}
+
+	return float64(to.Sub(from)) / float64(time.Millisecond)
+}
+
+func nonNegative(value float64) float64 {
+	if value < 0 {
+		return 0
+	}
+
+	return value
+}
+
+func serverIP(remoteAddress string) string {
+	host, _, err := net.SplitHostPort(remoteAddress)
+	if err != nil {
+		return remoteAddress
+	}
+
+	return host
+}
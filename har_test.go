@@ -0,0 +1,155 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHARRecorder(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"secret-token","user":"john"}`))
+		case "/large-json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"SECRET-TOKEN","padding":"` + strings.Repeat("a", 200) + `"}`))
+		case "/large":
+			w.Write(bytes.Repeat([]byte("a"), 100))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	t.Run("Records request and response", func(t *testing.T) {
+		recorder := NewHARRecorder()
+		client := NewHttpClient().
+			SetHARRecorder(recorder).
+			SetBodyMarshaler(NewJSONBodyMarshaler()).
+			SetHeader(HeaderAuthorization, "Bearer secret")
+
+		var result string
+		_, err := client.NewPostRequest(ctx, server.URL+"/login?api_key=secret&page=1", map[string]string{"password": "secret"}).
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Contains(result, "secret-token", "the caller must receive the original body")
+
+		har := recorder.HAR()
+		r.Equal("1.2", har.Log.Version)
+		r.Len(har.Log.Entries, 1)
+
+		entry := har.Log.Entries[0]
+		r.Equal(http.MethodPost, entry.Request.Method)
+		r.NotContains(entry.Request.URL, "secret")
+		r.Contains(entry.Request.QueryString, HARNameValue{Name: "page", Value: "1"})
+		r.Contains(entry.Request.Headers, HARNameValue{Name: HeaderAuthorization, Value: "[REDACTED]"})
+		r.NotNil(entry.Request.PostData)
+		r.Equal(`{"password":"[REDACTED]"}`, entry.Request.PostData.Text)
+
+		r.Equal(http.StatusOK, entry.Response.Status)
+		r.Equal("OK", entry.Response.StatusText)
+		r.Equal([]HARNameValue{{Name: "session", Value: "[REDACTED]"}}, entry.Response.Cookies)
+		r.Equal(`{"access_token":"[REDACTED]","user":"john"}`, entry.Response.Content.Text)
+		r.Equal(int64(len(result)), entry.Response.Content.Size)
+		r.Equal("127.0.0.1", entry.ServerIPAddress)
+		r.GreaterOrEqual(entry.Timings.Wait, float64(0))
+		r.GreaterOrEqual(entry.Timings.Receive, float64(0))
+		r.Greater(entry.Time, float64(0))
+	})
+
+	t.Run("Truncates bodies and writes file", func(t *testing.T) {
+		recorder := NewHARRecorder().SetMaxBodySize(10)
+		client := NewHttpClient().SetHARRecorder(recorder)
+
+		var result []byte
+		_, err := client.NewGetRequest(ctx, server.URL+"/large").WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Len(result, 100)
+
+		_, err = client.NewGetRequest(ctx, server.URL+"/missing").Do()
+		r.Error(err)
+
+		path := filepath.Join(t.TempDir(), "session.har")
+		r.NoError(recorder.WriteFile(path))
+
+		har := recorder.HAR()
+		r.Len(har.Log.Entries, 2)
+		r.Equal("aaaaaaaaaa", har.Log.Entries[0].Response.Content.Text)
+		r.Equal(int64(100), har.Log.Entries[0].Response.Content.Size)
+		r.Equal("truncated", har.Log.Entries[0].Response.Content.Comment)
+		r.Equal(http.StatusNotFound, har.Log.Entries[1].Response.Status)
+
+		buf := &bytes.Buffer{}
+		_, err = recorder.WriteTo(buf)
+		r.NoError(err)
+
+		var decoded map[string]interface{}
+		r.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+		r.Contains(decoded, "log")
+
+		recorder.Reset()
+		r.Empty(recorder.HAR().Log.Entries)
+	})
+
+	t.Run("Truncated JSON bodies are not recorded unredacted", func(t *testing.T) {
+		recorder := NewHARRecorder().SetMaxBodySize(100)
+		client := NewHttpClient().SetHARRecorder(recorder).SetBodyMarshaler(NewJSONBodyMarshaler())
+
+		body := map[string]string{"password": "HUNTER2", "padding": strings.Repeat("b", 200)}
+		var result []byte
+		_, err := client.NewPostRequest(ctx, server.URL+"/large-json", body).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Contains(string(result), "SECRET-TOKEN")
+
+		buf := &bytes.Buffer{}
+		_, err = recorder.WriteTo(buf)
+		r.NoError(err)
+		r.NotContains(buf.String(), "SECRET-TOKEN")
+		r.NotContains(buf.String(), "HUNTER2")
+
+		entry := recorder.HAR().Log.Entries[0]
+		r.Equal("<100 bytes of JSON, omitted as it can not be redacted>", entry.Request.PostData.Text)
+		r.Equal("truncated", entry.Request.PostData.Comment)
+		r.Equal("<100 bytes of JSON, omitted as it can not be redacted>", entry.Response.Content.Text)
+		r.Equal("truncated", entry.Response.Content.Comment)
+	})
+
+	t.Run("Records transport errors", func(t *testing.T) {
+		recorder := NewHARRecorder()
+		_, err := NewHttpClient().SetHARRecorder(recorder).NewGetRequest(ctx, "http://127.0.0.1:1/?token=secret").Do()
+		r.Error(err)
+
+		entries := recorder.HAR().Log.Entries
+		r.Len(entries, 1)
+		r.Equal(0, entries[0].Response.Status)
+		r.NotEmpty(entries[0].Comment)
+		r.NotContains(entries[0].Comment, "secret")
+
+		// HAR 1.2 allows -1 only for blocked, dns, connect and ssl
+		timings := entries[0].Timings
+		r.Zero(timings.Send)
+		r.Zero(timings.Wait)
+		r.Zero(timings.Receive)
+	})
+
+	t.Run("Missing phases", func(t *testing.T) {
+		timings, total := harTimings(traceTimes{})
+		r.Equal(HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}, timings)
+		r.Zero(total)
+	})
+}
@@ -0,0 +1,155 @@
+package httpreqx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SetIdempotent marks the request as safe to be sent more than once, which allows hedging (see SetHedging) requests with non-idempotent methods, e.g. POST.
+// Requests with the GET, HEAD, OPTIONS, TRACE, PUT and DELETE methods are idempotent by default.
+func (r *Request) SetIdempotent(idempotent bool) *Request {
+	r.idempotent = idempotent
+	return r
+}
+
+// SetHedging enables hedged requests to reduce the tail latency: if no response arrives within the delay,
+// a duplicate attempt is sent, up to maxExtra duplicates, each after another delay. A failed attempt (transport error or 5xx status) is replaced by a duplicate right away.
+// The first successful response wins, the other attempts are canceled through their contexts and their bodies are drained and closed,
+// so only the body of the winner reaches WriteBodyTo. When all attempts fail, the last failure is returned.
+// Do fails without sending anything when the request is not idempotent, see SetIdempotent.
+func (r *Request) SetHedging(delay time.Duration, maxExtra int) *Request {
+	r.hedgeDelay = delay
+	r.hedgeMaxExtra = maxExtra
+	return r
+}
+
+func (r *Request) isIdempotent() bool {
+	if r.idempotent {
+		return true
+	}
+
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+type hedgedResult struct {
+	attemptResult
+	index  int
+	cancel context.CancelFunc
+}
+
+// hedgedAttempt sends the attempt and its duplicates and returns the first successful result.
+func (r *Request) hedgedAttempt(ctx context.Context) attemptResult {
+	maxAttempts := 1 + r.hedgeMaxExtra
+	results := make(chan hedgedResult, maxAttempts)
+
+	var cancels []context.CancelFunc
+	launched, pending := 0, 0
+	launch := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		index := launched
+		launched++
+		pending++
+		go func() {
+			results <- hedgedResult{attemptResult: r.attempt(attemptCtx), index: index, cancel: cancel}
+		}()
+	}
+
+	timer := time.NewTimer(r.hedgeDelay)
+	defer timer.Stop()
+
+	launch()
+	var last *hedgedResult
+	for pending > 0 {
+		var hedge <-chan time.Time
+		if launched < maxAttempts {
+			hedge = timer.C
+		}
+
+		select {
+		case <-hedge:
+			launch()
+			timer.Reset(r.hedgeDelay)
+		case result := <-results:
+			pending--
+
+			if result.err == nil && result.resp.StatusCode < 500 {
+				// The other attempts are canceled right away, their results are released in the background.
+				for i, cancel := range cancels {
+					if i != result.index {
+						cancel()
+					}
+				}
+				go discardHedgedResults(results, pending)
+				if last != nil {
+					last.discard()
+				}
+				return result.winner()
+			}
+
+			if last != nil {
+				last.discard()
+			}
+			last = &result
+
+			if launched < maxAttempts {
+				launch()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(r.hedgeDelay)
+			}
+		}
+	}
+
+	return last.winner()
+}
+
+// winner returns the result, its context is canceled once the body is closed.
+func (h *hedgedResult) winner() attemptResult {
+	if h.resp == nil || h.resp.Body == nil {
+		h.cancel()
+		return h.attemptResult
+	}
+
+	h.resp.Body = &cancelOnCloseBody{ReadCloser: h.resp.Body, cancel: h.cancel}
+	return h.attemptResult
+}
+
+// discard cancels the attempt and releases its response.
+func (h *hedgedResult) discard() {
+	h.cancel()
+	discardBody(h.resp)
+}
+
+// discardHedgedResults waits for the attempts that are still in flight and discards them.
+func discardHedgedResults(results <-chan hedgedResult, pending int) {
+	for ; pending > 0; pending-- {
+		result := <-results
+		result.discard()
+	}
+}
+
+// cancelOnCloseBody cancels the context of the request once the body is closed, so the winning attempt is not canceled before its body is read.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+	return err
+}
@@ -0,0 +1,127 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedging(t *testing.T) {
+	r := require.New(t)
+
+	var hits, canceled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+
+		switch req.URL.Path {
+		case "/slow-first":
+			if n == 1 {
+				select {
+				case <-req.Context().Done():
+					atomic.AddInt32(&canceled, 1)
+					return
+				case <-time.After(2 * time.Second):
+				}
+			}
+		case "/fail-first":
+			if n == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintf(w, "attempt %d", n)
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+	ctx := context.Background()
+
+	reset := func() {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&canceled, 0)
+	}
+
+	t.Run("Duplicate wins and the slow attempt is canceled", func(t *testing.T) {
+		reset()
+
+		var result string
+		start := time.Now()
+		_, err := client.NewGetRequest(ctx, server.URL+"/slow-first").
+			SetHedging(20*time.Millisecond, 2).
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Equal("attempt 2", result)
+		r.Less(time.Since(start), time.Second)
+
+		r.Eventually(func() bool { return atomic.LoadInt32(&canceled) == 1 }, time.Second, 5*time.Millisecond)
+		r.Equal(int32(2), atomic.LoadInt32(&hits), "no more duplicates after the winner")
+	})
+
+	t.Run("Response size is reported for the winner", func(t *testing.T) {
+		reset()
+
+		metrics := NewInMemoryMetrics()
+		var result string
+		_, err := client.Clone().SetMetricsRecorder(metrics).NewGetRequest(ctx, server.URL+"/fail-first").
+			SetHedging(time.Second, 1).
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Equal("attempt 2", result)
+
+		labels := MetricLabels{Method: http.MethodGet, Host: server.Listener.Addr().String(), Route: "/fail-first", StatusClass: "2xx"}
+		r.Equal(int64(1), metrics.Requests(labels))
+		r.Equal([]float64{float64(len("attempt 2"))}, metrics.ResponseSizes(labels))
+	})
+
+	t.Run("Failed attempt is replaced right away", func(t *testing.T) {
+		reset()
+
+		var result string
+		_, err := client.NewGetRequest(ctx, server.URL+"/fail-first").
+			SetHedging(time.Second, 1).
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Equal("attempt 2", result)
+	})
+
+	t.Run("All attempts fail", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/fail").SetHedging(time.Millisecond, 2).Do()
+		var requestErr *RequestError
+		r.True(errors.As(err, &requestErr))
+		r.Equal(http.StatusServiceUnavailable, requestErr.StatusCode)
+		r.Equal(int32(3), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Non-idempotent requests are refused", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewPostRequest(ctx, server.URL, nil).SetHedging(time.Millisecond, 1).Do()
+		r.ErrorContains(err, "SetIdempotent")
+		r.Zero(atomic.LoadInt32(&hits))
+
+		var result string
+		_, err = client.NewPostRequest(ctx, server.URL+"/slow-first", nil).
+			SetIdempotent(true).
+			SetHedging(20*time.Millisecond, 1).
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Equal("attempt 2", result)
+	})
+}
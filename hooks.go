@@ -6,4 +6,9 @@ type OnRequestReadyHook func(req *http.Request) error
 
 type OnResponseReadyHook func(resp *http.Response) error
 
-type onErrorHook func(req *http.Request, resp *http.Response, err error, body interface{})
+// OnTimingsHook receives the phase timings of the request.
+// For successful requests it is called once the response body is fully read or closed, err is nil in this case.
+// For failed requests it is called right after the error occurred with the timings collected so far.
+type OnTimingsHook func(req *http.Request, timings Timings, err error)
+
+type onErrorHook func(req *http.Request, resp *http.Response, err error, body interface{}, redactor *Redactor)
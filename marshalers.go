@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -86,3 +87,44 @@ func (m *NoopBodyMarshaler) OnRequestReady(_ *http.Request) error {
 func NewNoopBodyMarshaler() BodyMarshaler {
 	return &NoopBodyMarshaler{}
 }
+
+type FormBodyMarshaler struct{}
+
+func (m *FormBodyMarshaler) Marshal(body interface{}, writer io.Writer) error {
+	if body == nil {
+		return errors.New("body is nil")
+	}
+
+	var values url.Values
+	switch v := body.(type) {
+	case url.Values:
+		values = v
+	case map[string][]string:
+		values = v
+	case map[string]string:
+		values = make(url.Values, len(v))
+		for key, value := range v {
+			values.Set(key, value)
+		}
+	default:
+		return fmt.Errorf("unsupported body type for FormBodyMarshaler: %T", body)
+	}
+
+	_, err := io.WriteString(writer, values.Encode())
+	return err
+}
+
+func (m *FormBodyMarshaler) OnRequestReady(req *http.Request) error {
+	req.Header.Set(HeaderContentType, "application/x-www-form-urlencoded")
+	return nil
+}
+
+// NewFormBodyMarshaler creates a BodyMarshaler that encodes the body as application/x-www-form-urlencoded.
+// It automatically sets the Content-Type header to application/x-www-form-urlencoded.
+// Allowed body types are:
+// - url.Values
+// - map[string][]string
+// - map[string]string
+func NewFormBodyMarshaler() BodyMarshaler {
+	return &FormBodyMarshaler{}
+}
@@ -0,0 +1,227 @@
+package httpreqx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricLabelNames are the names of the labels in the order returned by MetricLabels.Values.
+var MetricLabelNames = []string{"method", "host", "route", "status_class"}
+
+// MetricLabels are attached to every metric reported to the MetricsRecorder.
+// Route is the route template (the request path before path params are applied, see Request.SetPathParam) rather than the raw path, to keep the cardinality bounded.
+// StatusClass is one of "1xx"-"5xx", or "error" when no response was received.
+type MetricLabels struct {
+	Method      string
+	Host        string
+	Route       string
+	StatusClass string
+}
+
+// Values returns the label values in the order of MetricLabelNames.
+func (l MetricLabels) Values() []string {
+	return []string{l.Method, l.Host, l.Route, l.StatusClass}
+}
+
+// MetricsRecorder receives the metrics of every request executed with Request.Do.
+// The methods map one-to-one to Prometheus collectors, e.g. a CounterVec, a GaugeVec and two HistogramVecs created with MetricLabelNames.
+// Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	// IncRequests counts a finished request.
+	IncRequests(labels MetricLabels)
+	// AddInFlight changes the number of requests in progress by delta. StatusClass of the labels is always empty.
+	AddInFlight(labels MetricLabels, delta float64)
+	// ObserveLatency records the duration of the request in seconds, including the body consumption when WriteBodyTo is used.
+	ObserveLatency(labels MetricLabels, seconds float64)
+	// ObserveResponseSize records the number of response body bytes read. It is reported once the body is fully read or closed.
+	ObserveResponseSize(labels MetricLabels, bytes float64)
+}
+
+func (r *Request) metricLabels() MetricLabels {
+	labels := MetricLabels{
+		Method: r.method,
+		Route:  r.routeTemplate(),
+	}
+
+	if u, err := url.Parse(r.url()); err == nil {
+		labels.Host = u.Host
+	}
+
+	return labels
+}
+
+func statusClass(resp *http.Response) string {
+	if resp == nil || resp.StatusCode < 100 || resp.StatusCode > 599 {
+		return "error"
+	}
+
+	return strconv.Itoa(resp.StatusCode/100) + "xx"
+}
+
+// observeMetrics reports the finished request and arranges the response size to be reported once the body is consumed.
+// The size is taken from the counter, resp.Body might be wrapped by the hedging, progress and dump handling.
+func observeMetrics(metrics MetricsRecorder, labels MetricLabels, start time.Time, resp *http.Response, counter *countingBody) {
+	labels.StatusClass = statusClass(resp)
+
+	metrics.IncRequests(labels)
+	metrics.ObserveLatency(labels, time.Since(start).Seconds())
+
+	if resp == nil || counter == nil {
+		return
+	}
+
+	counter.onDone(func(size int64) {
+		metrics.ObserveResponseSize(labels, float64(size))
+	})
+}
+
+// countingBody counts the bytes read from the body and reports the total once the body is fully read or closed.
+type countingBody struct {
+	io.ReadCloser
+	mu       sync.Mutex
+	size     int64
+	finished bool
+	callback func(size int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	b.size += int64(n)
+	b.mu.Unlock()
+
+	if err != nil && errors.Is(err, io.EOF) {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *countingBody) finish() {
+	b.mu.Lock()
+	if b.finished {
+		b.mu.Unlock()
+		return
+	}
+	b.finished = true
+	callback, size := b.callback, b.size
+	b.mu.Unlock()
+
+	if callback != nil {
+		callback(size)
+	}
+}
+
+// onDone sets the callback, it is called immediately if the body is already consumed.
+func (b *countingBody) onDone(callback func(size int64)) {
+	b.mu.Lock()
+	if !b.finished {
+		b.callback = callback
+		b.mu.Unlock()
+		return
+	}
+	size := b.size
+	b.mu.Unlock()
+
+	callback(size)
+}
+
+// InMemoryMetrics is a dependency-free MetricsRecorder that keeps all observations in memory.
+// It is intended for tests and debugging, the observations are never evicted.
+type InMemoryMetrics struct {
+	mu            sync.Mutex
+	requests      map[MetricLabels]int64
+	inFlight      map[MetricLabels]float64
+	latencies     map[MetricLabels][]float64
+	responseSizes map[MetricLabels][]float64
+}
+
+// NewInMemoryMetrics creates an empty InMemoryMetrics.
+func NewInMemoryMetrics() *InMemoryMetrics {
+	return &InMemoryMetrics{
+		requests:      make(map[MetricLabels]int64),
+		inFlight:      make(map[MetricLabels]float64),
+		latencies:     make(map[MetricLabels][]float64),
+		responseSizes: make(map[MetricLabels][]float64),
+	}
+}
+
+func (m *InMemoryMetrics) IncRequests(labels MetricLabels) {
+	m.mu.Lock()
+	m.requests[labels]++
+	m.mu.Unlock()
+}
+
+func (m *InMemoryMetrics) AddInFlight(labels MetricLabels, delta float64) {
+	m.mu.Lock()
+	m.inFlight[labels] += delta
+	m.mu.Unlock()
+}
+
+func (m *InMemoryMetrics) ObserveLatency(labels MetricLabels, seconds float64) {
+	m.mu.Lock()
+	m.latencies[labels] = append(m.latencies[labels], seconds)
+	m.mu.Unlock()
+}
+
+func (m *InMemoryMetrics) ObserveResponseSize(labels MetricLabels, bytes float64) {
+	m.mu.Lock()
+	m.responseSizes[labels] = append(m.responseSizes[labels], bytes)
+	m.mu.Unlock()
+}
+
+// Requests returns the number of finished requests with the labels.
+func (m *InMemoryMetrics) Requests(labels MetricLabels) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.requests[labels]
+}
+
+// InFlight returns the number of requests in progress with the labels. StatusClass of the labels must be empty.
+func (m *InMemoryMetrics) InFlight(labels MetricLabels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.inFlight[labels]
+}
+
+// Latencies returns the observed latencies in seconds for the labels.
+func (m *InMemoryMetrics) Latencies(labels MetricLabels) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]float64(nil), m.latencies[labels]...)
+}
+
+// ResponseSizes returns the observed response sizes in bytes for the labels.
+func (m *InMemoryMetrics) ResponseSizes(labels MetricLabels) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]float64(nil), m.responseSizes[labels]...)
+}
+
+// Labels returns all label sets with at least one finished request.
+func (m *InMemoryMetrics) Labels() []MetricLabels {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := make([]MetricLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+
+	return labels
+}
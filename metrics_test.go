@@ -0,0 +1,77 @@
+package httpreqx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/users/42", "/users/a%2Fb":
+			w.Write([]byte("user"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	r.NoError(err)
+
+	ctx := context.Background()
+	metrics := NewInMemoryMetrics()
+	client := NewHttpClient().SetMetricsRecorder(metrics)
+
+	t.Run("Labels by route template", func(t *testing.T) {
+		for _, id := range []string{"42", "a/b"} {
+			var result string
+			_, err := client.NewGetRequest(ctx, server.URL+"/users/{id}").
+				SetPathParam("id", id).
+				WriteBodyTo(&result).
+				Do()
+			r.NoError(err)
+			r.Equal("user", result)
+		}
+
+		labels := MetricLabels{Method: http.MethodGet, Host: serverURL.Host, Route: "/users/{id}", StatusClass: "2xx"}
+		r.Equal(int64(2), metrics.Requests(labels))
+		r.Len(metrics.Latencies(labels), 2)
+		r.Equal([]float64{4, 4}, metrics.ResponseSizes(labels))
+
+		inFlight := labels
+		inFlight.StatusClass = ""
+		r.Equal(float64(0), metrics.InFlight(inFlight))
+	})
+
+	t.Run("Error status and manual body handling", func(t *testing.T) {
+		resp, err := client.NewGetRequest(ctx, server.URL+"/missing").Do()
+		r.Error(err)
+
+		labels := MetricLabels{Method: http.MethodGet, Host: serverURL.Host, Route: "/missing", StatusClass: "4xx"}
+		r.Equal(int64(1), metrics.Requests(labels))
+		r.Empty(metrics.ResponseSizes(labels), "size is reported once the body is consumed")
+
+		_, err = io.ReadAll(resp.Body)
+		r.NoError(err)
+		r.NoError(resp.Body.Close())
+		r.Equal([]float64{0}, metrics.ResponseSizes(labels))
+	})
+
+	t.Run("Transport error", func(t *testing.T) {
+		_, err := client.NewGetRequest(ctx, "http://127.0.0.1:1/health").Do()
+		r.Error(err)
+
+		labels := MetricLabels{Method: http.MethodGet, Host: "127.0.0.1:1", Route: "/health", StatusClass: "error"}
+		r.Equal(int64(1), metrics.Requests(labels))
+		r.Contains(metrics.Labels(), labels)
+	})
+}
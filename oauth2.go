@@ -0,0 +1,222 @@
+package httpreqx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultOAuth2ExpiryDelta = 10 * time.Second
+
+// OAuth2ClientCredentials is an Authenticator implementing the OAuth2 client credentials grant (RFC 6749, section 4.4).
+// The token is cached until shortly before its expiry. Concurrent requests share a single token refresh.
+// When a request receives 401 Unauthorized, the cached token is dropped and the request is repeated once with a new token.
+type OAuth2ClientCredentials struct {
+	tokenURL       string
+	clientID       string
+	clientSecret   string
+	scopes         []string
+	endpointParams url.Values
+	authInBody     bool
+	expiryDelta    time.Duration
+	client         *HttpClient
+	now            func() time.Time
+
+	mu         sync.Mutex
+	token      *OAuth2Token
+	refreshing *oauth2TokenCall
+}
+
+// OAuth2Token is an access token issued by the token endpoint.
+type OAuth2Token struct {
+	AccessToken string
+	TokenType   string
+	// Expiry is zero if the token endpoint did not provide expires_in.
+	Expiry time.Time
+}
+
+// authorization returns the value of the Authorization header for the token.
+func (t *OAuth2Token) authorization() string {
+	return t.TokenType + " " + t.AccessToken
+}
+
+type oauth2TokenCall struct {
+	done  chan struct{}
+	token *OAuth2Token
+	err   error
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	ExpiresIn   json.Number `json:"expires_in"`
+}
+
+// NewOAuth2ClientCredentials creates an OAuth2ClientCredentials authenticator.
+// Tokens are requested with a dedicated HttpClient using the FormBodyMarshaler and JSONBodyUnmarshaler.
+// The client credentials are sent via HTTP Basic authentication, use SetAuthInBody to send them as form parameters instead.
+func NewOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *OAuth2ClientCredentials {
+	return &OAuth2ClientCredentials{
+		tokenURL:       tokenURL,
+		clientID:       clientID,
+		clientSecret:   clientSecret,
+		scopes:         scopes,
+		endpointParams: url.Values{},
+		expiryDelta:    defaultOAuth2ExpiryDelta,
+		client: NewHttpClient().
+			SetBodyMarshaler(NewFormBodyMarshaler()).
+			SetBodyUnmarshaler(NewJSONBodyUnmarshaler()),
+		now: time.Now,
+	}
+}
+
+// SetHttpClient sets the client used to request tokens. Its BodyMarshaler and BodyUnmarshaler are overridden for the token requests, without affecting the client.
+// The client must not use this authenticator itself.
+func (a *OAuth2ClientCredentials) SetHttpClient(client *HttpClient) *OAuth2ClientCredentials {
+	a.client = client
+	return a
+}
+
+// SetEndpointParam sets an additional form parameter sent to the token endpoint, e.g. "audience".
+func (a *OAuth2ClientCredentials) SetEndpointParam(key, value string) *OAuth2ClientCredentials {
+	a.endpointParams.Set(key, value)
+	return a
+}
+
+// SetAuthInBody configures sending client_id and client_secret as form parameters instead of HTTP Basic authentication.
+func (a *OAuth2ClientCredentials) SetAuthInBody(enabled bool) *OAuth2ClientCredentials {
+	a.authInBody = enabled
+	return a
+}
+
+// SetExpiryDelta sets how long before the expiry a token is considered expired and refreshed. Default is 10 seconds.
+func (a *OAuth2ClientCredentials) SetExpiryDelta(delta time.Duration) *OAuth2ClientCredentials {
+	a.expiryDelta = delta
+	return a
+}
+
+func (a *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderAuthorization, token.authorization())
+	return nil
+}
+
+func (a *OAuth2ClientCredentials) HandleUnauthorized(req *http.Request, _ *http.Response) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Another request might have refreshed the token already, it is kept in this case.
+	if a.token != nil && req.Header.Get(HeaderAuthorization) == a.token.authorization() {
+		a.token = nil
+	}
+
+	return true
+}
+
+// Token returns the cached token or requests a new one if it is missing or about to expire.
+// Only one token request is made at a time, concurrent callers wait for its result.
+// The token request is made with the context of the caller that started it,
+// if it fails because that context is done, the waiting callers with a live context request the token again.
+func (a *OAuth2ClientCredentials) Token(ctx context.Context) (*OAuth2Token, error) {
+	a.mu.Lock()
+	if a.token != nil && a.valid(a.token) {
+		token := a.token
+		a.mu.Unlock()
+		return token, nil
+	}
+
+	call := a.refreshing
+	started := call == nil
+	if started {
+		call = &oauth2TokenCall{done: make(chan struct{})}
+		a.refreshing = call
+		a.mu.Unlock()
+
+		call.token, call.err = a.fetchToken(ctx)
+
+		a.mu.Lock()
+		if call.err == nil {
+			a.token = call.token
+		}
+		a.refreshing = nil
+		a.mu.Unlock()
+		close(call.done)
+	} else {
+		a.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if !started && call.err != nil && isContextError(call.err) && ctx.Err() == nil {
+		return a.Token(ctx)
+	}
+
+	return call.token, call.err
+}
+
+func (a *OAuth2ClientCredentials) valid(token *OAuth2Token) bool {
+	return token.Expiry.IsZero() || a.now().Add(a.expiryDelta).Before(token.Expiry)
+}
+
+func (a *OAuth2ClientCredentials) fetchToken(ctx context.Context) (*OAuth2Token, error) {
+	params := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		params.Set("scope", strings.Join(a.scopes, " "))
+	}
+	for key, values := range a.endpointParams {
+		params[key] = values
+	}
+	if a.authInBody {
+		params.Set("client_id", a.clientID)
+		params.Set("client_secret", a.clientSecret)
+	}
+
+	req := a.client.NewPostRequest(ctx, a.tokenURL, params).
+		SetBodyMarshaler(NewFormBodyMarshaler()).
+		SetBodyUnmarshaler(NewJSONBodyUnmarshaler())
+
+	if !a.authInBody {
+		// The credentials are form-encoded before being used for HTTP Basic authentication (RFC 6749, section 2.3.1).
+		credentials := url.QueryEscape(a.clientID) + ":" + url.QueryEscape(a.clientSecret)
+		req.SetHeader(HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	issuedAt := a.now()
+
+	var tokenResponse oauth2TokenResponse
+	if _, err := req.WriteBodyTo(&tokenResponse).Do(); err != nil {
+		return nil, fmt.Errorf("oauth2 token request: %w", err)
+	}
+
+	if tokenResponse.AccessToken == "" {
+		return nil, errors.New("oauth2 token request: access_token is missing in the response")
+	}
+
+	token := &OAuth2Token{
+		AccessToken: tokenResponse.AccessToken,
+		TokenType:   tokenResponse.TokenType,
+	}
+	if token.TokenType == "" || strings.EqualFold(token.TokenType, "bearer") {
+		token.TokenType = "Bearer"
+	}
+	if expiresIn, err := tokenResponse.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.Expiry = issuedAt.Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,482 @@
+package httpreqx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const defaultMaxPages = 1000
+
+// ErrMaxPagesReached is returned by the Paginator when there are more pages than allowed by SetMaxPages.
+var ErrMaxPagesReached = errors.New("max pages reached")
+
+// ErrCrossOriginLink is returned by the LinkPagination when the next link points to another scheme or host,
+// the credentials of the request must not be sent there.
+var ErrCrossOriginLink = errors.New("next link points to another origin")
+
+// PaginationStrategy decides which page is requested next.
+type PaginationStrategy interface {
+	// First returns the URL of the first page, based on the URL of the request template.
+	First(u *url.URL) *url.URL
+	// Next returns the URL of the page following the current one, or nil if the current page is the last one.
+	// The page is the decoded body of the current response, which is already closed.
+	Next(current *url.URL, resp *http.Response, page interface{}) (*url.URL, error)
+}
+
+// CursorExtractor returns the cursor of the next page from the decoded page, an empty cursor ends the pagination.
+type CursorExtractor func(page interface{}) (string, error)
+
+// PageItemCounter returns the number of items in the decoded page.
+type PageItemCounter func(page interface{}) (int, error)
+
+// Paginator repeatedly executes a Request template, following the pages selected by the PaginationStrategy.
+// Every page is decoded with the BodyUnmarshaler of the request into a new value created by the newPage function.
+// The request template must not use WriteBodyTo, the pages are executed with copies of it, so the template is not modified.
+//
+// Pages can be iterated:
+//
+//	for paginator.Next() {
+//		page := paginator.Page().(*UsersPage)
+//	}
+//	if err := paginator.Err(); err != nil {
+//		return err
+//	}
+//
+// or consumed with ForEach. The context of the request cancels the pagination.
+type Paginator struct {
+	request  *Request
+	strategy PaginationStrategy
+	newPage  func() interface{}
+	maxPages int
+
+	started bool
+	done    bool
+	pages   int
+	current *url.URL
+	page    interface{}
+	resp    *http.Response
+	err     error
+}
+
+// NewPaginator creates a Paginator fetching at most 1000 pages.
+func NewPaginator(request *Request, strategy PaginationStrategy, newPage func() interface{}) *Paginator {
+	return &Paginator{
+		request:  request,
+		strategy: strategy,
+		newPage:  newPage,
+		maxPages: defaultMaxPages,
+	}
+}
+
+// SetMaxPages sets the maximum number of pages, Err returns ErrMaxPagesReached if there are more pages. Zero or a negative value removes the limit.
+func (p *Paginator) SetMaxPages(maxPages int) *Paginator {
+	p.maxPages = maxPages
+	return p
+}
+
+// Next fetches the next page and reports whether it is available. It returns false at the end of the pages or on an error, see Err.
+func (p *Paginator) Next() bool {
+	if p.done {
+		return false
+	}
+
+	next, err := p.nextURL()
+	if err != nil || next == nil {
+		return p.stop(err)
+	}
+
+	if p.maxPages > 0 && p.pages >= p.maxPages {
+		return p.stop(ErrMaxPagesReached)
+	}
+	if err := p.request.ctx.Err(); err != nil {
+		return p.stop(err)
+	}
+
+	page := p.newPage()
+	pageRequest := *p.request
+	pageRequest.urlOverride = next.String()
+	pageRequest.WriteBodyTo(page)
+
+	resp, err := pageRequest.Do()
+	if err != nil {
+		return p.stop(err)
+	}
+
+	p.pages++
+	p.current = next
+	p.page = page
+	p.resp = resp
+
+	return true
+}
+
+// nextURL returns the URL of the first page, or the URL following the current page.
+func (p *Paginator) nextURL() (*url.URL, error) {
+	if !p.started {
+		p.started = true
+
+		u, err := url.Parse(p.request.url())
+		if err != nil {
+			return nil, p.request.requestError(ErrorPhaseRequest, nil, fmt.Errorf("pagination: %w", err))
+		}
+
+		return p.strategy.First(u), nil
+	}
+
+	next, err := p.strategy.Next(p.current, p.resp, p.page)
+	if err != nil {
+		return nil, fmt.Errorf("pagination: %w", err)
+	}
+
+	return next, nil
+}
+
+func (p *Paginator) stop(err error) bool {
+	p.done = true
+	p.err = err
+	p.page = nil
+	p.resp = nil
+	return false
+}
+
+// Page returns the decoded current page, the value created by the newPage function.
+func (p *Paginator) Page() interface{} {
+	return p.page
+}
+
+// Response returns the response of the current page. The body is already consumed and closed.
+func (p *Paginator) Response() *http.Response {
+	return p.resp
+}
+
+// PageCount returns the number of pages fetched so far.
+func (p *Paginator) PageCount() int {
+	return p.pages
+}
+
+// Err returns the error which stopped the pagination, nil if all pages were fetched.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// ForEach calls the callback for every page, an error returned by the callback stops the pagination and is returned as is.
+func (p *Paginator) ForEach(callback func(page interface{}, resp *http.Response) error) error {
+	for p.Next() {
+		if err := callback(p.Page(), p.Response()); err != nil {
+			p.stop(nil)
+			return err
+		}
+	}
+
+	return p.Err()
+}
+
+// LinkPagination follows the RFC 8288 Link header with the rel="next" relation, e.g. `<https://api.example.com/users?page=2>; rel="next"`.
+// Links to another origin (scheme and host) fail with ErrCrossOriginLink, as the pages are requested with the same headers and credentials.
+type LinkPagination struct{}
+
+// NewLinkPagination creates a LinkPagination.
+func NewLinkPagination() *LinkPagination {
+	return &LinkPagination{}
+}
+
+func (s *LinkPagination) First(u *url.URL) *url.URL {
+	return u
+}
+
+func (s *LinkPagination) Next(current *url.URL, resp *http.Response, _ interface{}) (*url.URL, error) {
+	for _, link := range parseLinkHeader(resp.Header.Values(HeaderLink)) {
+		for _, rel := range strings.Fields(link.params["rel"]) {
+			if !strings.EqualFold(rel, "next") {
+				continue
+			}
+
+			next, err := current.Parse(link.target)
+			if err != nil {
+				return nil, fmt.Errorf("next link: %w", err)
+			}
+			if !strings.EqualFold(next.Scheme, current.Scheme) || !strings.EqualFold(next.Host, current.Host) {
+				return nil, fmt.Errorf("%w: %s", ErrCrossOriginLink, next.Scheme+"://"+next.Host)
+			}
+
+			return next, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type linkValue struct {
+	target string
+	params map[string]string
+}
+
+// parseLinkHeader parses the values of the Link header, the parameter names are lowercased.
+func parseLinkHeader(headers []string) []linkValue {
+	var links []linkValue
+
+	for _, header := range headers {
+		i := 0
+		skip := func(chars string) {
+			for i < len(header) && strings.IndexByte(chars, header[i]) >= 0 {
+				i++
+			}
+		}
+		readToken := func() string {
+			start := i
+			for i < len(header) && strings.IndexByte(" \t,;=\"", header[i]) < 0 {
+				i++
+			}
+			return header[start:i]
+		}
+
+		for {
+			skip(" \t,")
+			if i >= len(header) {
+				break
+			}
+			if header[i] != '<' {
+				// Not a link, the rest of the value up to the next link is skipped.
+				i++
+				continue
+			}
+
+			end := strings.IndexByte(header[i:], '>')
+			if end < 0 {
+				break
+			}
+
+			link := linkValue{target: header[i+1 : i+end], params: make(map[string]string)}
+			i += end + 1
+
+			for {
+				skip(" \t")
+				if i >= len(header) || header[i] != ';' {
+					break
+				}
+				i++
+				skip(" \t")
+
+				name := strings.ToLower(readToken())
+				skip(" \t")
+				if i >= len(header) || header[i] != '=' {
+					link.params[name] = ""
+					continue
+				}
+				i++
+				skip(" \t")
+
+				var value string
+				if i < len(header) && header[i] == '"' {
+					value = readQuotedString(header, &i)
+				} else {
+					value = readToken()
+				}
+
+				// Only the first occurrence of a parameter is used.
+				if _, ok := link.params[name]; !ok && name != "" {
+					link.params[name] = value
+				}
+			}
+
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// CursorPagination sends the cursor extracted from the current page in the query parameter of the next request.
+type CursorPagination struct {
+	param     string
+	extractor CursorExtractor
+}
+
+// NewCursorPagination creates a CursorPagination, see JSONCursorField for extracting the cursor from a JSON field.
+func NewCursorPagination(param string, extractor CursorExtractor) *CursorPagination {
+	return &CursorPagination{param: param, extractor: extractor}
+}
+
+func (s *CursorPagination) First(u *url.URL) *url.URL {
+	return u
+}
+
+func (s *CursorPagination) Next(current *url.URL, _ *http.Response, page interface{}) (*url.URL, error) {
+	cursor, err := s.extractor(page)
+	if err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+	if cursor == "" || cursor == current.Query().Get(s.param) {
+		return nil, nil
+	}
+
+	return withQueryParam(current, s.param, cursor), nil
+}
+
+// PageNumberPagination increments the page number query parameter until an empty page is received.
+type PageNumberPagination struct {
+	param   string
+	counter PageItemCounter
+}
+
+// NewPageNumberPagination creates a PageNumberPagination starting with the page 1, unless the request template sets the parameter.
+// A nil counter counts the items of pages decoded into slices.
+func NewPageNumberPagination(param string, counter PageItemCounter) *PageNumberPagination {
+	return &PageNumberPagination{param: param, counter: counter}
+}
+
+func (s *PageNumberPagination) First(u *url.URL) *url.URL {
+	if u.Query().Has(s.param) {
+		return u
+	}
+
+	return withQueryParam(u, s.param, "1")
+}
+
+func (s *PageNumberPagination) Next(current *url.URL, _ *http.Response, page interface{}) (*url.URL, error) {
+	count, err := countPageItems(s.counter, page)
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	number, err := strconv.Atoi(current.Query().Get(s.param))
+	if err != nil {
+		return nil, fmt.Errorf("page number: %w", err)
+	}
+
+	return withQueryParam(current, s.param, strconv.Itoa(number+1)), nil
+}
+
+// OffsetPagination advances the offset query parameter by the number of received items until a page shorter than the limit is received.
+type OffsetPagination struct {
+	offsetParam string
+	limitParam  string
+	limit       int
+	counter     PageItemCounter
+}
+
+// NewOffsetPagination creates an OffsetPagination sending the limit in the limitParam query parameter.
+// The first page starts with the offset of the request template, 0 by default. A nil counter counts the items of pages decoded into slices.
+func NewOffsetPagination(offsetParam, limitParam string, limit int, counter PageItemCounter) *OffsetPagination {
+	return &OffsetPagination{offsetParam: offsetParam, limitParam: limitParam, limit: limit, counter: counter}
+}
+
+func (s *OffsetPagination) First(u *url.URL) *url.URL {
+	return withQueryParam(u, s.limitParam, strconv.Itoa(s.limit))
+}
+
+func (s *OffsetPagination) Next(current *url.URL, _ *http.Response, page interface{}) (*url.URL, error) {
+	count, err := countPageItems(s.counter, page)
+	if err != nil || count == 0 || count < s.limit {
+		return nil, err
+	}
+
+	offset := 0
+	if value := current.Query().Get(s.offsetParam); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("offset: %w", err)
+		}
+	}
+
+	return withQueryParam(current, s.offsetParam, strconv.Itoa(offset+count)), nil
+}
+
+func withQueryParam(u *url.URL, name, value string) *url.URL {
+	next := *u
+	query := next.Query()
+	query.Set(name, value)
+	next.RawQuery = query.Encode()
+	return &next
+}
+
+// countPageItems counts the items with the counter, or the length of the page if it is a slice (or a pointer to one).
+func countPageItems(counter PageItemCounter, page interface{}) (int, error) {
+	if counter != nil {
+		return counter(page)
+	}
+
+	value := reflect.ValueOf(page)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return 0, fmt.Errorf("page item counter is required for %T pages", page)
+	}
+
+	return value.Len(), nil
+}
+
+// JSONCursorField returns a CursorExtractor reading the string or number field at the path of the page encoded as JSON,
+// e.g. JSONCursorField("meta", "next_cursor"). A missing or null field ends the pagination.
+func JSONCursorField(path ...string) CursorExtractor {
+	return func(page interface{}) (string, error) {
+		value, err := jsonPath(page, path)
+		if err != nil {
+			return "", err
+		}
+
+		switch v := value.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		default:
+			return "", fmt.Errorf("field %q is %T, not a string or number", strings.Join(path, "."), value)
+		}
+	}
+}
+
+// JSONItemCount returns a PageItemCounter reading the length of the array at the path of the page encoded as JSON,
+// e.g. JSONItemCount("data"). A missing or null field counts as an empty page.
+func JSONItemCount(path ...string) PageItemCounter {
+	return func(page interface{}) (int, error) {
+		value, err := jsonPath(page, path)
+		if err != nil {
+			return 0, err
+		}
+
+		switch v := value.(type) {
+		case nil:
+			return 0, nil
+		case []interface{}:
+			return len(v), nil
+		default:
+			return 0, fmt.Errorf("field %q is %T, not an array", strings.Join(path, "."), value)
+		}
+	}
+}
+
+// jsonPath encodes the page as JSON and returns the value at the path, nil if it is missing.
+func jsonPath(page interface{}, path []string) (interface{}, error) {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		value = object[name]
+	}
+
+	return value, nil
+}
@@ -0,0 +1,219 @@
+package httpreqx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type paginatorTestPage struct {
+	Items []int `json:"items"`
+	Meta  struct {
+		NextCursor *string `json:"next_cursor"`
+	} `json:"meta"`
+}
+
+func TestPaginator(t *testing.T) {
+	r := require.New(t)
+
+	items := make([]int, 25)
+	for i := range items {
+		items[i] = i
+	}
+
+	slice := func(from, to int) []int {
+		if from > len(items) {
+			from = len(items)
+		}
+		if to > len(items) {
+			to = len(items)
+		}
+		return items[from:to]
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		w.Header().Set(HeaderContentType, "application/json")
+
+		switch req.URL.Path {
+		case "/link":
+			page, _ := strconv.Atoi(query.Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			if page*10 < len(items) {
+				w.Header().Add(HeaderLink, `</link?page=1>; rel="first"`)
+				w.Header().Add(HeaderLink, fmt.Sprintf(`</link?page=%d>; rel="next last"`, page+1))
+			}
+			_ = json.NewEncoder(w).Encode(slice((page-1)*10, page*10))
+		case "/cursor":
+			from, _ := strconv.Atoi(query.Get("cursor"))
+			var page paginatorTestPage
+			page.Items = slice(from, from+10)
+			if from+10 < len(items) {
+				next := strconv.Itoa(from + 10)
+				page.Meta.NextCursor = &next
+			}
+			_ = json.NewEncoder(w).Encode(page)
+		case "/pages":
+			page, _ := strconv.Atoi(query.Get("page"))
+			_ = json.NewEncoder(w).Encode(map[string][]int{"items": slice((page-1)*10, page*10)})
+		case "/offset":
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			_ = json.NewEncoder(w).Encode(slice(offset, offset+limit))
+		case "/loop":
+			w.Header().Set(HeaderLink, `</loop>; rel="next"`)
+			_ = json.NewEncoder(w).Encode([]int{1})
+		case "/cross-origin":
+			w.Header().Set(HeaderLink, `<https://evil.example.com/link?page=2>; rel="next"`)
+			_ = json.NewEncoder(w).Encode([]int{1})
+		case "/fail":
+			if query.Get("page") == "2" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(HeaderLink, `<?page=2>; rel="next"`)
+			_ = json.NewEncoder(w).Encode([]int{1})
+		}
+	}))
+	defer server.Close()
+
+	client := NewHttpClient().SetBodyUnmarshaler(NewJSONBodyUnmarshaler())
+	ctx := context.Background()
+
+	newSlicePage := func() interface{} { return &[]int{} }
+
+	t.Run("Link header", func(t *testing.T) {
+		paginator := NewPaginator(client.NewGetRequest(ctx, server.URL+"/link"), NewLinkPagination(), newSlicePage)
+
+		var received []int
+		for paginator.Next() {
+			received = append(received, *paginator.Page().(*[]int)...)
+			r.Equal(http.StatusOK, paginator.Response().StatusCode)
+		}
+		r.NoError(paginator.Err())
+		r.Equal(items, received)
+		r.Equal(3, paginator.PageCount())
+	})
+
+	t.Run("Cross-origin next link is refused", func(t *testing.T) {
+		paginator := NewPaginator(client.NewGetRequest(ctx, server.URL+"/cross-origin").SetBearerToken("secret"), NewLinkPagination(), newSlicePage)
+
+		r.True(paginator.Next())
+		r.False(paginator.Next())
+		r.ErrorIs(paginator.Err(), ErrCrossOriginLink)
+		r.Equal(1, paginator.PageCount())
+	})
+
+	t.Run("JSON cursor", func(t *testing.T) {
+		paginator := NewPaginator(
+			client.NewGetRequest(ctx, server.URL+"/cursor"),
+			NewCursorPagination("cursor", JSONCursorField("meta", "next_cursor")),
+			func() interface{} { return &paginatorTestPage{} },
+		)
+
+		var received []int
+		err := paginator.ForEach(func(page interface{}, resp *http.Response) error {
+			received = append(received, page.(*paginatorTestPage).Items...)
+			return nil
+		})
+		r.NoError(err)
+		r.Equal(items, received)
+	})
+
+	t.Run("Page numbers", func(t *testing.T) {
+		paginator := NewPaginator(
+			client.NewGetRequest(ctx, server.URL+"/pages"),
+			NewPageNumberPagination("page", JSONItemCount("items")),
+			func() interface{} { return &map[string][]int{} },
+		)
+
+		var received []int
+		r.NoError(paginator.ForEach(func(page interface{}, _ *http.Response) error {
+			received = append(received, (*page.(*map[string][]int))["items"]...)
+			return nil
+		}))
+		r.Equal(items, received)
+		// The last page is empty
+		r.Equal(4, paginator.PageCount())
+	})
+
+	t.Run("Offsets", func(t *testing.T) {
+		paginator := NewPaginator(
+			client.NewGetRequest(ctx, server.URL+"/offset"),
+			NewOffsetPagination("offset", "limit", 10, nil),
+			newSlicePage,
+		)
+
+		var received []int
+		r.NoError(paginator.ForEach(func(page interface{}, _ *http.Response) error {
+			received = append(received, *page.(*[]int)...)
+			return nil
+		}))
+		r.Equal(items, received)
+		r.Equal(3, paginator.PageCount())
+	})
+
+	t.Run("Max pages guard", func(t *testing.T) {
+		paginator := NewPaginator(client.NewGetRequest(ctx, server.URL+"/loop"), NewLinkPagination(), newSlicePage).SetMaxPages(5)
+
+		err := paginator.ForEach(func(interface{}, *http.Response) error { return nil })
+		r.ErrorIs(err, ErrMaxPagesReached)
+		r.Equal(5, paginator.PageCount())
+	})
+
+	t.Run("Callback error stops the pagination", func(t *testing.T) {
+		stop := errors.New("stop")
+		paginator := NewPaginator(client.NewGetRequest(ctx, server.URL+"/loop"), NewLinkPagination(), newSlicePage)
+
+		err := paginator.ForEach(func(interface{}, *http.Response) error { return stop })
+		r.ErrorIs(err, stop)
+		r.Equal(1, paginator.PageCount())
+		r.False(paginator.Next())
+	})
+
+	t.Run("Request error", func(t *testing.T) {
+		paginator := NewPaginator(client.NewGetRequest(ctx, server.URL+"/fail"), NewLinkPagination(), newSlicePage)
+
+		r.True(paginator.Next())
+		r.False(paginator.Next())
+
+		var requestErr *RequestError
+		r.ErrorAs(paginator.Err(), &requestErr)
+		r.Equal(http.StatusInternalServerError, requestErr.StatusCode)
+		r.Contains(requestErr.URL, "/fail?page=2")
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		paginator := NewPaginator(client.NewGetRequest(ctx, server.URL+"/loop"), NewLinkPagination(), newSlicePage)
+
+		r.True(paginator.Next())
+		cancel()
+		r.False(paginator.Next())
+		r.ErrorIs(paginator.Err(), context.Canceled)
+	})
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	r := require.New(t)
+
+	links := parseLinkHeader([]string{
+		`<https://api.example.com/items?page=2&a=b,c>; rel="next"; title="next, page", <https://api.example.com/items?page=5>;REL=last`,
+		`<https://api.example.com/items?page=1>; rel="first prev"`,
+	})
+	r.Len(links, 3)
+	r.Equal("https://api.example.com/items?page=2&a=b,c", links[0].target)
+	r.Equal("next", links[0].params["rel"])
+	r.Equal("next, page", links[0].params["title"])
+	r.Equal("last", links[1].params["rel"])
+	r.Equal("first prev", links[2].params["rel"])
+}
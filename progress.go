@@ -0,0 +1,95 @@
+package httpreqx
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress callbacks, the final one is always reported.
+const progressInterval = 100 * time.Millisecond
+
+// UploadProgressHook receives the number of request body bytes sent so far and the total size, -1 if the size is unknown.
+type UploadProgressHook func(sent, total int64)
+
+// progressBody reports the number of bytes read from the body, at most once per progressInterval.
+type progressBody struct {
+	io.ReadCloser
+	total    int64
+	hook     func(transferred, total int64)
+	count    int64
+	reported int64
+	last     time.Time
+	now      func() time.Time
+}
+
+func newProgressBody(body io.ReadCloser, total int64, hook func(transferred, total int64)) *progressBody {
+	return &progressBody{ReadCloser: body, total: total, hook: hook, reported: -1, now: time.Now}
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.count += int64(n)
+
+	complete := err == io.EOF || (b.total >= 0 && b.count >= b.total)
+	if n > 0 || complete {
+		b.report(complete)
+	}
+
+	return n, err
+}
+
+func (b *progressBody) report(complete bool) {
+	if b.count == b.reported {
+		return
+	}
+
+	now := b.now()
+	if !complete && b.reported >= 0 && now.Sub(b.last) < progressInterval {
+		return
+	}
+
+	b.last = now
+	b.reported = b.count
+	b.hook(b.count, b.total)
+}
+
+// progressTotal returns the Content-Length, -1 if it is unknown.
+func progressTotal(contentLength int64) int64 {
+	if contentLength <= 0 {
+		return -1
+	}
+
+	return contentLength
+}
+
+// trackUploadProgress wraps the request body, and the copies of it made for redirects, with the upload progress hook.
+func (r *Request) trackUploadProgress(req *http.Request) {
+	hook := r.options.OnUploadProgress
+	if hook == nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	total := progressTotal(req.ContentLength)
+	req.Body = newProgressBody(req.Body, total, hook)
+
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil || body == http.NoBody {
+				return body, err
+			}
+			return newProgressBody(body, total, hook), nil
+		}
+	}
+}
+
+// trackDownloadProgress wraps the response body, which is read by the BodyUnmarshaler or the caller, with the download progress hook.
+func (r *Request) trackDownloadProgress(resp *http.Response) {
+	hook := r.options.OnDownloadProgress
+	if hook == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	resp.Body = newProgressBody(resp.Body, progressTotal(resp.ContentLength), hook)
+}
@@ -0,0 +1,188 @@
+package httpreqx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	r := require.New(t)
+
+	payload := bytes.Repeat([]byte("x"), 256*1024)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/upload":
+			_, _ = io.Copy(io.Discard, req.Body)
+			w.WriteHeader(http.StatusNoContent)
+		case "/chunked":
+			for i := 0; i < 4; i++ {
+				_, _ = w.Write(payload[:1024])
+				w.(http.Flusher).Flush()
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write(payload[:1024])
+		case "/redirect":
+			http.Redirect(w, req, "/upload", http.StatusTemporaryRedirect)
+		default:
+			w.Header().Set(HeaderContentLength, strconv.Itoa(len(payload)))
+			_, _ = w.Write(payload)
+		}
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+	ctx := context.Background()
+
+	type progress struct{ transferred, total int64 }
+	var mu sync.Mutex
+	var calls []progress
+	record := func(transferred, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, progress{transferred, total})
+	}
+	reset := func() []progress {
+		mu.Lock()
+		defer mu.Unlock()
+		recorded := calls
+		calls = nil
+		return recorded
+	}
+
+	t.Run("Upload progress", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewPostRequest(ctx, server.URL+"/upload", payload).SetOnUploadProgress(record).Do()
+		r.NoError(err)
+
+		recorded := reset()
+		r.NotEmpty(recorded)
+		r.Equal(progress{int64(len(payload)), int64(len(payload))}, recorded[len(recorded)-1])
+	})
+
+	t.Run("Upload progress restarts for redirects", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewPostRequest(ctx, server.URL+"/redirect", payload).SetOnUploadProgress(record).Do()
+		r.NoError(err)
+
+		completed := 0
+		for _, call := range reset() {
+			if call.transferred == int64(len(payload)) {
+				completed++
+			}
+		}
+		r.Equal(2, completed)
+	})
+
+	t.Run("Download progress with WriteBodyTo", func(t *testing.T) {
+		reset()
+
+		var body []byte
+		_, err := client.NewGetRequest(ctx, server.URL+"/download").SetOnDownloadProgress(record).WriteBodyTo(&body).Do()
+		r.NoError(err)
+		r.Len(body, len(payload))
+
+		recorded := reset()
+		r.NotEmpty(recorded)
+		r.Equal(progress{int64(len(payload)), int64(len(payload))}, recorded[len(recorded)-1])
+	})
+
+	t.Run("Download progress with unknown size", func(t *testing.T) {
+		reset()
+
+		client := NewHttpClient().SetOnDownloadProgress(record)
+		resp, err := client.NewGetRequest(ctx, server.URL+"/chunked").Do()
+		r.NoError(err)
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		r.NoError(err)
+		r.Len(data, 4096)
+
+		recorded := reset()
+		r.Equal(progress{4096, -1}, recorded[len(recorded)-1])
+	})
+
+	t.Run("Response size metrics with progress and dumps", func(t *testing.T) {
+		reset()
+
+		metrics := NewInMemoryMetrics()
+		client := NewHttpClient().SetMetricsRecorder(metrics).SetOnDownloadProgress(record)
+		host := server.Listener.Addr().String()
+
+		var body []byte
+		_, err := client.NewGetRequest(ctx, server.URL+"/download").WriteBodyTo(&body).Do()
+		r.NoError(err)
+		r.Len(body, len(payload))
+		r.NotEmpty(reset())
+		r.Equal([]float64{float64(len(payload))}, metrics.ResponseSizes(MetricLabels{Method: http.MethodGet, Host: host, Route: "/download", StatusClass: "2xx"}))
+
+		var resp *http.Response
+		captureStdout(t, func() {
+			resp, err = client.Clone().SetDumpOnError().NewGetRequest(ctx, server.URL+"/missing").Do()
+		})
+		r.Error(err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		r.NoError(err)
+		r.NoError(resp.Body.Close())
+		r.Equal([]float64{1024}, metrics.ResponseSizes(MetricLabels{Method: http.MethodGet, Host: host, Route: "/missing", StatusClass: "4xx"}))
+	})
+
+	t.Run("Requests without hooks are not wrapped", func(t *testing.T) {
+		resp, err := client.NewGetRequest(ctx, server.URL+"/download").Do()
+		r.NoError(err)
+		defer resp.Body.Close()
+
+		_, wrapped := resp.Body.(*progressBody)
+		r.False(wrapped)
+	})
+}
+
+func TestProgressBodyThrottling(t *testing.T) {
+	r := require.New(t)
+
+	clock := &testClock{now: time.Unix(0, 0)}
+	var calls []int64
+	body := newProgressBody(io.NopCloser(strings.NewReader("abcdefghij")), 10, func(transferred, _ int64) {
+		calls = append(calls, transferred)
+	})
+	body.now = clock.Now
+
+	buf := make([]byte, 2)
+	read := func() {
+		_, err := body.Read(buf)
+		r.NoError(err)
+	}
+
+	read()
+	r.Equal([]int64{2}, calls)
+
+	read()
+	read()
+	r.Equal([]int64{2}, calls)
+
+	clock.Advance(progressInterval)
+	read()
+	r.Equal([]int64{2, 8}, calls)
+
+	// The final progress is reported regardless of the interval
+	read()
+	r.Equal([]int64{2, 8, 10}, calls)
+
+	_, err := body.Read(buf)
+	r.ErrorIs(err, io.EOF)
+	r.Equal([]int64{2, 8, 10}, calls)
+}
@@ -0,0 +1,291 @@
+package httpreqx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// epochThreshold separates reset values sent as Unix timestamps from the ones sent as seconds until the reset.
+const epochThreshold = 1_000_000_000
+
+// RateLimiter limits the rate of the requests made with a client with token buckets, set with HttpClient.SetRateLimiter.
+// Limits can be set globally, per host (every host has its own bucket) and per route template (see Request.SetPathParam),
+// a request waits until all buckets it belongs to have a token, or its context is done.
+// The limiter adapts to the limits announced by the servers: when the X-RateLimit-Remaining or RateLimit-Remaining (or RateLimit with the r/remaining parameter)
+// response header drops to 0, requests to the host wait until the time of X-RateLimit-Reset/RateLimit-Reset (t/reset) passes.
+// The same applies to 429 and 503 responses with the Retry-After header. A lower remaining value also drains the host bucket.
+// Every attempt of a request takes a token.
+type RateLimiter struct {
+	global     *rateLimit
+	host       *rateLimit
+	routes     map[string]rateLimit
+	now        func() time.Time
+	adaptation bool
+
+	mu           sync.Mutex
+	buckets      map[string]*tokenBucket
+	blockedUntil map[string]time.Time
+}
+
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+type tokenBucket struct {
+	limit  rateLimit
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter without limits. Adaptation to the rate limit response headers is enabled.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		routes:       make(map[string]rateLimit),
+		now:          time.Now,
+		adaptation:   true,
+		buckets:      make(map[string]*tokenBucket),
+		blockedUntil: make(map[string]time.Time),
+	}
+}
+
+// SetGlobalLimit limits all requests to rate requests per second, with bursts of up to burst requests.
+// A rate of 0 or less removes the limit, a burst less than 1 is raised to 1.
+func (l *RateLimiter) SetGlobalLimit(rate float64, burst int) *RateLimiter {
+	l.mu.Lock()
+	l.global = newRateLimit(rate, burst)
+	l.mu.Unlock()
+	return l
+}
+
+// SetHostLimit limits the requests to every host to rate requests per second, with bursts of up to burst requests.
+// A rate of 0 or less removes the limit, a burst less than 1 is raised to 1.
+func (l *RateLimiter) SetHostLimit(rate float64, burst int) *RateLimiter {
+	l.mu.Lock()
+	l.host = newRateLimit(rate, burst)
+	l.mu.Unlock()
+	return l
+}
+
+// SetRouteLimit limits the requests with the route template, e.g. "/users/{id}", to rate requests per second, with bursts of up to burst requests.
+// A rate of 0 or less removes the limit, a burst less than 1 is raised to 1.
+func (l *RateLimiter) SetRouteLimit(route string, rate float64, burst int) *RateLimiter {
+	l.mu.Lock()
+	if limit := newRateLimit(rate, burst); limit != nil {
+		l.routes[route] = *limit
+	} else {
+		delete(l.routes, route)
+	}
+	l.mu.Unlock()
+	return l
+}
+
+// SetAdaptation enables or disables the adaptation to the rate limit response headers.
+func (l *RateLimiter) SetAdaptation(enabled bool) *RateLimiter {
+	l.mu.Lock()
+	l.adaptation = enabled
+	l.mu.Unlock()
+	return l
+}
+
+// newRateLimit returns the limit, or nil if the rate does not limit anything.
+func newRateLimit(rate float64, burst int) *rateLimit {
+	if !(rate > 0) {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimit{rate: rate, burst: float64(burst)}
+}
+
+// wait blocks until the request can be sent or the context is done.
+func (l *RateLimiter) wait(ctx context.Context, host, route string) error {
+	for {
+		delay := l.take(host, route)
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("rate limiter: %w", ctx.Err())
+		}
+	}
+}
+
+// take takes a token from every bucket of the request, or returns the time to wait if any of them is empty.
+func (l *RateLimiter) take(host, route string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if blockedUntil, ok := l.blockedUntil[host]; ok {
+		if delay := blockedUntil.Sub(now); delay > 0 {
+			return delay
+		}
+		delete(l.blockedUntil, host)
+	}
+
+	var buckets []*tokenBucket
+	if l.global != nil {
+		buckets = append(buckets, l.bucket("global", *l.global, now))
+	}
+	if l.host != nil {
+		buckets = append(buckets, l.bucket("host "+host, *l.host, now))
+	}
+	if limit, ok := l.routes[route]; ok {
+		buckets = append(buckets, l.bucket("route "+route, limit, now))
+	}
+
+	var delay time.Duration
+	for _, bucket := range buckets {
+		if bucketDelay := bucket.delay(now); bucketDelay > delay {
+			delay = bucketDelay
+		}
+	}
+	if delay > 0 {
+		return delay
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return 0
+}
+
+func (l *RateLimiter) bucket(key string, limit rateLimit, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{limit: limit, tokens: limit.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	// The limit might have been changed since the bucket was created.
+	bucket.limit = limit
+	bucket.refill(now)
+	return bucket
+}
+
+// observe adapts the limits of the host to the rate limit headers of the response.
+func (l *RateLimiter) observe(host string, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.adaptation {
+		return
+	}
+
+	now := l.now()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			l.block(host, now.Add(retryAfter))
+		}
+	}
+
+	remaining, reset, ok := parseRateLimitHeaders(resp.Header, now)
+	if !ok {
+		return
+	}
+
+	if remaining <= 0 {
+		l.block(host, now.Add(reset))
+		return
+	}
+
+	if bucket, ok := l.buckets["host "+host]; ok && float64(remaining) < bucket.tokens {
+		bucket.tokens = float64(remaining)
+	}
+}
+
+func (l *RateLimiter) block(host string, until time.Time) {
+	if until.After(l.blockedUntil[host]) {
+		l.blockedUntil[host] = until
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * b.limit.rate
+		if b.tokens > b.limit.burst {
+			b.tokens = b.limit.burst
+		}
+		b.last = now
+	}
+}
+
+// delay returns the time until the bucket has a token.
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.limit.rate * float64(time.Second))
+}
+
+// parseRateLimitHeaders returns the remaining requests and the time until the reset announced by the response.
+// Reset values larger than epochThreshold are treated as Unix timestamps.
+func parseRateLimitHeaders(header http.Header, now time.Time) (remaining int64, reset time.Duration, ok bool) {
+	remainingValue, resetValue := header.Get("X-RateLimit-Remaining"), header.Get("X-RateLimit-Reset")
+	if remainingValue == "" {
+		remainingValue, resetValue = header.Get("RateLimit-Remaining"), header.Get("RateLimit-Reset")
+	}
+	if remainingValue == "" {
+		// Structured field of the later drafts, e.g. `"default";r=0;t=30` or `limit=100, remaining=0, reset=30`.
+		for _, param := range strings.FieldsFunc(header.Get("RateLimit"), func(r rune) bool { return r == ';' || r == ',' }) {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch strings.ToLower(name) {
+			case "r", "remaining":
+				remainingValue = value
+			case "t", "reset":
+				resetValue = value
+			}
+		}
+	}
+
+	remaining, err := strconv.ParseInt(strings.TrimSpace(remainingValue), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	resetSeconds, err := strconv.ParseFloat(strings.TrimSpace(resetValue), 64)
+	if err != nil || resetSeconds < 0 {
+		return remaining, 0, true
+	}
+	if resetSeconds > epochThreshold {
+		return remaining, time.Unix(int64(resetSeconds), 0).Sub(now), true
+	}
+
+	return remaining, time.Duration(resetSeconds * float64(time.Second)), true
+}
+
+// parseRetryAfter parses the Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+
+	return 0, false
+}
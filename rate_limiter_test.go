@@ -0,0 +1,122 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	t.Run("Global limit", func(t *testing.T) {
+		client := NewHttpClient().SetRateLimiter(NewRateLimiter().SetGlobalLimit(20, 2))
+
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			_, err := client.NewGetRequest(ctx, server.URL).Do()
+			r.NoError(err)
+		}
+		r.GreaterOrEqual(time.Since(start), 90*time.Millisecond, "two requests wait for a token, 50ms each")
+	})
+
+	t.Run("Invalid limits and concurrent changes", func(t *testing.T) {
+		limiter := NewRateLimiter().SetGlobalLimit(0, 1).SetHostLimit(-5, 1).SetRouteLimit("/", 10, 0)
+		client := NewHttpClient().SetRateLimiter(limiter)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 50; i++ {
+				limiter.SetGlobalLimit(0, 0).SetRouteLimit("/", 0, 0).SetAdaptation(i%2 == 0)
+			}
+		}()
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			_, err := client.NewGetRequest(ctx, server.URL).Do()
+			r.NoError(err)
+		}
+		<-done
+		r.Less(time.Since(start), time.Second, "a zero or negative rate does not limit anything")
+	})
+
+	t.Run("Route limit and context", func(t *testing.T) {
+		client := NewHttpClient().SetRateLimiter(NewRateLimiter().SetRouteLimit("/users/{id}", 0.1, 1))
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/users/{id}").SetPathParam("id", "1").Do()
+		r.NoError(err)
+
+		start := time.Now()
+		_, err = client.NewGetRequest(ctx, server.URL+"/other").Do()
+		r.NoError(err)
+		r.Less(time.Since(start), 50*time.Millisecond, "other routes are not limited")
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err = client.NewGetRequest(timeoutCtx, server.URL+"/users/{id}").SetPathParam("id", "2").Do()
+		r.ErrorIs(err, context.DeadlineExceeded)
+
+		var requestErr *RequestError
+		r.True(errors.As(err, &requestErr))
+		r.Equal(ErrorPhaseRejected, requestErr.Phase)
+	})
+
+	t.Run("Adapts to response headers", func(t *testing.T) {
+		clock := &testClock{now: time.Unix(1700000000, 0)}
+		limiter := NewRateLimiter().SetHostLimit(100, 10)
+		limiter.now = clock.Now
+
+		r.Zero(limiter.take("api", ""))
+
+		limiter.observe("api", &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+			"X-Ratelimit-Remaining": {"2"},
+			"X-Ratelimit-Reset":     {"1700000030"},
+		}})
+		r.Zero(limiter.take("api", ""))
+		r.Zero(limiter.take("api", ""))
+		r.Greater(limiter.take("api", ""), time.Duration(0), "the bucket is drained to the remaining requests")
+
+		limiter.observe("api", &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+			"Ratelimit-Remaining": {"0"},
+			"Ratelimit-Reset":     {"30"},
+		}})
+		r.Equal(30*time.Second, limiter.take("api", ""))
+		r.Zero(limiter.take("other", ""), "other hosts are not blocked")
+
+		clock.Advance(30 * time.Second)
+		r.Zero(limiter.take("api", ""))
+
+		limiter.observe("api", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"5"}}})
+		r.Equal(5*time.Second, limiter.take("api", ""))
+	})
+
+	t.Run("Header formats", func(t *testing.T) {
+		now := time.Unix(1700000000, 0)
+
+		for _, header := range []http.Header{
+			{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1700000010"}},
+			{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"10"}},
+			{"Ratelimit": {`"default";r=0;t=10`}},
+			{"Ratelimit": {"limit=100, remaining=0, reset=10"}},
+		} {
+			remaining, reset, ok := parseRateLimitHeaders(header, now)
+			r.True(ok, header)
+			r.Zero(remaining)
+			r.Equal(10*time.Second, reset)
+		}
+
+		_, _, ok := parseRateLimitHeaders(http.Header{}, now)
+		r.False(ok)
+	})
+}
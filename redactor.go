@@ -0,0 +1,385 @@
+package httpreqx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultRedactionMask = "[REDACTED]"
+
+var (
+	defaultRedactedHeaders = []string{
+		HeaderAuthorization,
+		"Proxy-Authorization",
+		HeaderCookie,
+		HeaderSetCookie,
+		"X-Api-Key",
+		"X-Auth-Token",
+		HeaderAmzSecurityToken,
+	}
+	defaultRedactedQueryParams = []string{
+		"api_key",
+		"apikey",
+		"access_token",
+		"refresh_token",
+		"client_secret",
+		"password",
+		"token",
+		"X-Amz-Signature",
+		"X-Amz-Credential",
+		HeaderAmzSecurityToken,
+	}
+	defaultRedactedBodyFields = []string{
+		"password",
+		"secret",
+		"client_secret",
+		"token",
+		"access_token",
+		"refresh_token",
+		"id_token",
+		"api_key",
+	}
+)
+
+// Redactor masks sensitive data before it leaves the library in a human-readable form.
+// It is applied to request/response dumps, error messages and every other debugging output produced by the library.
+// Matching of header names, query parameters and body fields is case-insensitive.
+type Redactor struct {
+	mask        string
+	headers     map[string]struct{}
+	queryParams map[string]struct{}
+	bodyFields  map[string]struct{}
+	bodyPaths   [][]string
+}
+
+// NewRedactor creates a Redactor with the default deny-lists:
+// - Headers: Authorization, Proxy-Authorization, Cookie, Set-Cookie, X-Api-Key, X-Auth-Token, X-Amz-Security-Token
+// - Query parameters: api_key, apikey, access_token, refresh_token, client_secret, password, token, X-Amz-Signature, X-Amz-Credential, X-Amz-Security-Token
+// - Body fields: password, secret, client_secret, token, access_token, refresh_token, id_token, api_key
+// Use NewEmptyRedactor to start from a blank deny-list.
+func NewRedactor() *Redactor {
+	return NewEmptyRedactor().
+		AddHeaders(defaultRedactedHeaders...).
+		AddQueryParams(defaultRedactedQueryParams...).
+		AddBodyFields(defaultRedactedBodyFields...)
+}
+
+// NewEmptyRedactor creates a Redactor that does not mask anything until rules are added to it.
+func NewEmptyRedactor() *Redactor {
+	return &Redactor{
+		mask:        defaultRedactionMask,
+		headers:     make(map[string]struct{}),
+		queryParams: make(map[string]struct{}),
+		bodyFields:  make(map[string]struct{}),
+	}
+}
+
+// clone returns an independent copy of the Redactor, so rules can be added without affecting other users of the original.
+func (r *Redactor) clone() *Redactor {
+	clone := NewEmptyRedactor().SetMask(r.mask)
+	for name := range r.headers {
+		clone.headers[name] = struct{}{}
+	}
+	for name := range r.queryParams {
+		clone.queryParams[name] = struct{}{}
+	}
+	for name := range r.bodyFields {
+		clone.bodyFields[name] = struct{}{}
+	}
+	clone.bodyPaths = append(clone.bodyPaths, r.bodyPaths...)
+
+	return clone
+}
+
+// SetMask sets the replacement value for redacted data. Default is "[REDACTED]".
+func (r *Redactor) SetMask(mask string) *Redactor {
+	r.mask = mask
+	return r
+}
+
+// AddHeaders adds header names whose values will be masked.
+func (r *Redactor) AddHeaders(names ...string) *Redactor {
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+	return r
+}
+
+// AddQueryParams adds URL query parameter names whose values will be masked.
+func (r *Redactor) AddQueryParams(names ...string) *Redactor {
+	for _, name := range names {
+		r.queryParams[strings.ToLower(name)] = struct{}{}
+	}
+	return r
+}
+
+// AddBodyFields adds JSON body fields whose values will be masked.
+// A plain name (e.g. "password") matches the field at any depth.
+// A dotted path (e.g. "$.user.credentials.secret" or "user.credentials.secret") matches only that path from the root.
+// "*" can be used in a path to match any object key or array element (e.g. "$.items.*.token").
+// Plain names are also applied to application/x-www-form-urlencoded bodies.
+func (r *Redactor) AddBodyFields(fields ...string) *Redactor {
+	for _, field := range fields {
+		field = strings.TrimPrefix(strings.TrimPrefix(field, "$"), ".")
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, ".") {
+			r.bodyFields[strings.ToLower(field)] = struct{}{}
+			continue
+		}
+
+		r.bodyPaths = append(r.bodyPaths, strings.Split(strings.ToLower(field), "."))
+	}
+	return r
+}
+
+// RedactHeaderValue returns the masked value if the header is on the deny-list, otherwise the original value.
+func (r *Redactor) RedactHeaderValue(name, value string) string {
+	if r == nil {
+		return value
+	}
+
+	if _, ok := r.headers[http.CanonicalHeaderKey(name)]; ok {
+		return r.mask
+	}
+
+	return value
+}
+
+// RedactHeaders returns a copy of the headers with sensitive values masked.
+func (r *Redactor) RedactHeaders(headers http.Header) http.Header {
+	if headers == nil {
+		return nil
+	}
+
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		redactedValues := make([]string, len(values))
+		for i, value := range values {
+			redactedValues[i] = r.RedactHeaderValue(name, value)
+		}
+		redacted[name] = redactedValues
+	}
+
+	return redacted
+}
+
+// RedactURL returns the string representation of the URL with sensitive query parameters and the password of the userinfo masked.
+func (r *Redactor) RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	if r == nil {
+		return u.String()
+	}
+
+	redacted := *u
+	if redacted.User != nil {
+		if _, hasPassword := redacted.User.Password(); hasPassword {
+			redacted.User = url.UserPassword(redacted.User.Username(), r.mask)
+		}
+	}
+
+	if redacted.RawQuery != "" {
+		redacted.RawQuery = r.redactPairs(redacted.RawQuery, r.queryParams)
+	}
+
+	return redacted.String()
+}
+
+// RedactURLString is the same as RedactURL but works with a raw URL string.
+// If the string can not be parsed as URL it is returned unchanged.
+func (r *Redactor) RedactURLString(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return r.RedactURL(u)
+}
+
+// redactPairs masks values of the deny-listed names in a "key=value&..." string, preserving the original order and encoding.
+func (r *Redactor) redactPairs(raw string, names map[string]struct{}) string {
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, _, hasValue := strings.Cut(pair, "=")
+		if !hasValue {
+			continue
+		}
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		if _, ok := names[strings.ToLower(name)]; ok {
+			pairs[i] = key + "=" + url.QueryEscape(r.mask)
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// redactFieldValue masks the value of a plain body field, e.g. a form or multipart field.
+func (r *Redactor) redactFieldValue(name, value string) string {
+	if r == nil {
+		return value
+	}
+
+	if _, ok := r.bodyFields[strings.ToLower(name)]; ok {
+		return r.mask
+	}
+
+	return value
+}
+
+// RedactBody masks sensitive fields of a JSON or application/x-www-form-urlencoded body.
+// The contentType is used as a hint, bodies that look like JSON are handled as JSON regardless of it.
+// Bodies of other types are returned unchanged.
+func (r *Redactor) RedactBody(contentType string, body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if redacted, err := r.redactJSON(trimmed); err == nil {
+			return redacted
+		}
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		return []byte(r.redactPairs(string(trimmed), r.bodyFields))
+	}
+
+	return body
+}
+
+func (r *Redactor) redactJSON(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return json.Marshal(r.redactJSONValue(value, nil))
+}
+
+func (r *Redactor) redactJSONValue(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key))
+			if r.isRedactedField(childPath) {
+				v[key] = r.mask
+				continue
+			}
+			v[key] = r.redactJSONValue(child, childPath)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.redactJSONValue(child, append(path[:len(path):len(path)], "*"))
+		}
+	}
+
+	return value
+}
+
+func (r *Redactor) isRedactedField(path []string) bool {
+	if _, ok := r.bodyFields[path[len(path)-1]]; ok {
+		return true
+	}
+
+	for _, bodyPath := range r.bodyPaths {
+		if matchFieldPath(bodyPath, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchFieldPath(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+
+	for i := range pattern {
+		// Array elements are represented by "*" in the path, so they are only matched by a wildcard.
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RedactError masks sensitive query parameters in URLs carried by *url.Error, which the net/http client returns for transport failures.
+// The passed error is not modified: a copy of the *url.Error with the masked URL is returned, wrapping the original cause.
+// Errors wrapping a *url.Error keep their message, with the masked URL, and unwrap to the copy.
+// Other errors are returned unchanged.
+func (r *Redactor) RedactError(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	redacted := &url.Error{Op: urlErr.Op, URL: r.RedactURLString(urlErr.URL), Err: urlErr.Err}
+	if err == error(urlErr) {
+		return redacted
+	}
+
+	return &redactedError{
+		message: strings.ReplaceAll(err.Error(), urlErr.Error(), redacted.Error()),
+		err:     redacted,
+	}
+}
+
+// redactedError is an error wrapping a *url.Error, with the URL masked in its message.
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// withAPIKey returns the Redactor masking the API key with the given name, a copy of it if the name is not masked yet.
+func (r *Redactor) withAPIKey(location APIKeyLocation, name string) *Redactor {
+	switch location {
+	case APIKeyInHeader:
+		if _, ok := r.headers[http.CanonicalHeaderKey(name)]; !ok {
+			return r.clone().AddHeaders(name)
+		}
+	case APIKeyInQuery:
+		if _, ok := r.queryParams[strings.ToLower(name)]; !ok {
+			return r.clone().AddQueryParams(name)
+		}
+	}
+
+	return r
+}
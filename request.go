@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 type Request struct {
@@ -17,6 +20,19 @@ type Request struct {
 	unmarshalResultTo interface{}
 	unmarshalResult   bool
 	options           *RequestOptions
+	pathParams        map[string]string
+	marshaledBody     []byte
+	bodyMarshaled     bool
+	idempotent        bool
+	hedgeDelay        time.Duration
+	hedgeMaxExtra     int
+	idempotencyKey    string
+	// idempotencyKeyInUse is the key sent with all attempts of the current Do call, either the one set with SetIdempotencyKey or a generated one.
+	idempotencyKeyInUse string
+	// urlOverride replaces the URL built from the path and path params, e.g. for the pages of a Paginator. The path stays the route template.
+	urlOverride string
+	// responseCounter counts the body of the response returned by the current Do call, as other wrappers of the body hide it.
+	responseCounter *countingBody
 }
 
 // NewRequest creates a new Request with the specified method, path, and body.
@@ -69,6 +85,16 @@ func (c *HttpClient) NewTraceRequest(ctx context.Context, path string) *Request
 	return c.NewRequest(ctx, http.MethodTrace, path, nil)
 }
 
+// mutableOptions returns the options of the request for modification.
+// The request shares the options with the client until the first modification, then they are cloned, so the client is not affected.
+func (r *Request) mutableOptions() *RequestOptions {
+	if r.options == r.client.requestOptions {
+		r.options = r.client.requestOptions.Clone()
+	}
+
+	return r.options
+}
+
 // WriteBodyTo sets the destination for unmarshalling the response body.
 // This method will consume the response body and close it after reading.
 // This is the recommended way to consume the response body as it prevents resource leaks, provides type safety and a unified way to work with body.
@@ -80,41 +106,131 @@ func (r *Request) WriteBodyTo(result interface{}) *Request {
 	return r
 }
 
+// SetPathParam sets the value of a "{name}" placeholder in the request path, e.g. "https://api.example.com/users/{id}".
+// The value is escaped with url.PathEscape.
+// The path with placeholders is used as the route template label for metrics and tracing, which keeps the label cardinality bounded.
+func (r *Request) SetPathParam(name, value string) *Request {
+	if r.pathParams == nil {
+		r.pathParams = make(map[string]string)
+	}
+
+	r.pathParams[name] = value
+	return r
+}
+
+// SetPathParams sets the values of multiple "{name}" placeholders in the request path, see SetPathParam.
+func (r *Request) SetPathParams(params map[string]string) *Request {
+	for name, value := range params {
+		r.SetPathParam(name, value)
+	}
+	return r
+}
+
 // SetBodyMarshaler sets the BodyMarshaler at the request level. Does not affect the client.
 func (r *Request) SetBodyMarshaler(marshaler BodyMarshaler) *Request {
-	r.options.SetBodyMarshaler(marshaler)
+	r.mutableOptions().SetBodyMarshaler(marshaler)
 	return r
 }
 
 // SetBodyUnmarshaler sets the BodyUnmarshaler at the request level. Does not affect the client.
 func (r *Request) SetBodyUnmarshaler(unmarshaler BodyUnmarshaler) *Request {
-	r.options.SetBodyUnmarshaler(unmarshaler)
+	r.mutableOptions().SetBodyUnmarshaler(unmarshaler)
 	return r
 }
 
 // SetHeaders sets the headers for the request. This will override headers with the same name set at the client level but only for this request.
 func (r *Request) SetHeaders(headers map[string]string) *Request {
-	r.options.SetHeaders(headers)
+	r.mutableOptions().SetHeaders(headers)
 	return r
 }
 
 // SetHeader sets a single header for the request. This will override header with the same name set at the client level but only for this request.
 func (r *Request) SetHeader(key, value string) *Request {
-	r.options.SetHeader(key, value)
+	r.mutableOptions().SetHeader(key, value)
 	return r
 }
 
 // SetOnRequestReady sets a hook that will be called right after an http.Request is created and all headers and body are set.
 // This method will override any hooks set at the client level, without affecting the client, but only for this request.
 func (r *Request) SetOnRequestReady(onRequestReady OnRequestReadyHook) *Request {
-	r.options.SetOnRequestReady(onRequestReady)
+	r.mutableOptions().SetOnRequestReady(onRequestReady)
 	return r
 }
 
 // SetOnResponseReady sets a hook that will be called right after the response is received and before it is processed.
 // This method will override any hooks set at the client level, without affecting the client, but only for this request.
 func (r *Request) SetOnResponseReady(onResponseReady OnResponseReadyHook) *Request {
-	r.options.SetOnResponseReady(onResponseReady)
+	r.mutableOptions().SetOnResponseReady(onResponseReady)
+	return r
+}
+
+// SetOnTimings sets a hook that receives the phase timings of the request.
+// This method will override the hook set at the client level, without affecting the client, but only for this request.
+func (r *Request) SetOnTimings(onTimings OnTimingsHook) *Request {
+	r.mutableOptions().SetOnTimings(onTimings)
+	return r
+}
+
+// SetOnUploadProgress sets a hook that receives the number of request body bytes sent and the total size (-1 if unknown).
+// This method will override the hook set at the client level, without affecting the client, but only for this request.
+func (r *Request) SetOnUploadProgress(onUploadProgress UploadProgressHook) *Request {
+	r.mutableOptions().SetOnUploadProgress(onUploadProgress)
+	return r
+}
+
+// SetOnDownloadProgress sets a hook that receives the number of response body bytes read and the total size (-1 if unknown).
+// This method will override the hook set at the client level, without affecting the client, but only for this request.
+func (r *Request) SetOnDownloadProgress(onDownloadProgress DownloadProgressHook) *Request {
+	r.mutableOptions().SetOnDownloadProgress(onDownloadProgress)
+	return r
+}
+
+// SetAuth sets the Authenticator at the request level. Passing nil disables authentication for this request.
+func (r *Request) SetAuth(authenticator Authenticator) *Request {
+	r.mutableOptions().SetAuth(authenticator)
+	return r
+}
+
+// SetBasicAuth configures HTTP Basic authentication for the request. Overrides the client level authentication without affecting the client.
+func (r *Request) SetBasicAuth(username, password string) *Request {
+	r.mutableOptions().SetBasicAuth(username, password)
+	return r
+}
+
+// SetBearerToken configures the "Authorization: Bearer <token>" header for the request. Overrides the client level authentication without affecting the client.
+// The token can be a string, func() (string, error) or func(ctx context.Context) (string, error).
+func (r *Request) SetBearerToken(tokenOrFunc interface{}) *Request {
+	r.mutableOptions().SetBearerToken(tokenOrFunc)
+	return r
+}
+
+// SetAPIKey configures sending the API key in the header or query parameter with the given name. Overrides the client level authentication without affecting the client.
+func (r *Request) SetAPIKey(location APIKeyLocation, name, value string) *Request {
+	r.mutableOptions().SetAPIKey(location, name, value)
+	return r
+}
+
+// SetSigner sets the Signer at the request level. Passing nil disables signing for this request.
+func (r *Request) SetSigner(signer Signer) *Request {
+	r.mutableOptions().SetSigner(signer)
+	return r
+}
+
+// SetMaxResponseBodySize limits the size of the response body of this request, reading beyond the limit fails with ErrBodyTooLarge. Zero removes the limit.
+func (r *Request) SetMaxResponseBodySize(size int64) *Request {
+	r.mutableOptions().SetMaxResponseBodySize(size)
+	return r
+}
+
+// SetRetryPolicy sets the RetryPolicy at the request level. Passing nil disables retries for this request.
+func (r *Request) SetRetryPolicy(policy *RetryPolicy) *Request {
+	r.mutableOptions().SetRetryPolicy(policy)
+	return r
+}
+
+// SetAutoIdempotencyKey enables or disables generating the Idempotency-Key header for this request, if it is a POST or PATCH request.
+func (r *Request) SetAutoIdempotencyKey(enabled bool) *Request {
+	r.mutableOptions().SetAutoIdempotencyKey(enabled)
 	return r
 }
 
@@ -123,61 +239,239 @@ func (r *Request) SetOnResponseReady(onResponseReady OnResponseReadyHook) *Reque
 // Original body passed by the caller code will be logged as well, if it is set.
 // This method will also enable the StackTraceEnabled option, which will add a stack trace to the error if it occurs.
 func (r *Request) SetDumpOnError() *Request {
-	r.options.SetDumpOnError()
+	r.mutableOptions().SetDumpOnError()
+	return r
+}
+
+// SetCurlOnError configures printing of the error and the failed request as a curl command when an error occurs, instead of the raw dump set by SetDumpOnError.
+func (r *Request) SetCurlOnError() *Request {
+	r.mutableOptions().SetCurlOnError()
 	return r
 }
 
 // SetStackTraceEnabled enables or disables the stack trace in the error if it occurs.
 func (r *Request) SetStackTraceEnabled(enabled bool) *Request {
-	r.options.SetStackTraceEnabled(enabled)
+	r.mutableOptions().SetStackTraceEnabled(enabled)
+	return r
+}
+
+// SetRedactor sets the Redactor used to mask sensitive data in dumps and error messages at the request level.
+// Passing nil disables redaction for this request.
+func (r *Request) SetRedactor(redactor *Redactor) *Request {
+	r.mutableOptions().SetRedactor(redactor)
 	return r
 }
 
 // Do method executes the configured HTTP request and returns the http.Response.
 func (r *Request) Do() (*http.Response, error) {
-	var beforeRequestHooks []OnRequestReadyHook
+	metrics := r.client.metrics
+	if metrics == nil {
+		return r.do()
+	}
 
-	// TODO: consider using sync.Pool to reuse buffers for the request body. Might be beneficial for performance in high-load scenarios.
-	bodyBuffer := &bytes.Buffer{}
+	labels := r.metricLabels()
+	start := time.Now()
 
-	if r.body != nil {
-		bodyMarshaler := r.options.BodyMarshaler
+	metrics.AddInFlight(labels, 1)
+	defer metrics.AddInFlight(labels, -1)
+
+	r.responseCounter = nil
+	resp, err := r.do()
+	observeMetrics(metrics, labels, start, resp, r.responseCounter)
+
+	return resp, err
+}
+
+func (r *Request) do() (*http.Response, error) {
+	if r.hedgeMaxExtra > 0 && !r.isIdempotent() {
+		err := fmt.Errorf("hedging of %s requests requires the request to be marked as idempotent with SetIdempotent", r.method)
+		return nil, r.processError(ErrorPhaseRequest, nil, nil, err, r.body)
+	}
+
+	if r.client.circuitBreaker == nil && r.client.bulkhead == nil {
+		return r.execute()
+	}
 
-		if bodyMarshaler == nil {
-			return nil, r.processError(nil, nil, errors.New("body marshaler is not set"), r.body)
+	labels := r.metricLabels()
+
+	if bulkhead := r.client.bulkhead; bulkhead != nil {
+		release, err := bulkhead.acquire(r.ctx, labels.Host)
+		if err != nil {
+			return nil, r.processError(ErrorPhaseRejected, nil, nil, err, r.body)
+		}
+		defer release()
+	}
+
+	if breaker := r.client.circuitBreaker; breaker != nil {
+		done, err := breaker.allow(labels.Method, labels.Host, labels.Route)
+		if err != nil {
+			return nil, r.processError(ErrorPhaseRejected, nil, nil, err, r.body)
 		}
 
-		beforeRequestHooks = append(beforeRequestHooks, bodyMarshaler.OnRequestReady)
+		resp, err := r.execute()
+		done(resp, err)
+		return resp, err
+	}
+
+	return r.execute()
+}
 
-		if err := bodyMarshaler.Marshal(r.body, bodyBuffer); err != nil {
-			return nil, r.processError(nil, nil, fmt.Errorf("body marshaling: %w", err), r.body)
+// execute marshals the body and runs the attempts of the request.
+func (r *Request) execute() (*http.Response, error) {
+	if _, err := r.marshalBody(); err != nil {
+		return nil, r.processError(ErrorPhaseMarshal, nil, nil, err, r.body)
+	}
+
+	r.idempotencyKeyInUse = r.resolveIdempotencyKey()
+
+	unauthorizedRetried := false
+	retries := 0
+	for attempt := 1; ; attempt++ {
+		ctx, span := r.startSpan(attempt)
+		result := r.roundTrip(ctx)
+
+		// The request is repeated once with fresh credentials if the authenticator is able to handle the 401 response.
+		if result.err == nil && !unauthorizedRetried && r.retryUnauthorized(result.req, result.resp) {
+			unauthorizedRetried = true
+			discardBody(result.resp)
+			endSpan(span, result.resp, nil)
+			continue
 		}
+
+		if delay, ok := r.retryDelay(retries+1, result); ok {
+			retries++
+			discardBody(result.resp)
+			if result.err != nil {
+				endSpan(span, nil, r.requestError(result.phase, nil, result.err))
+			} else {
+				endSpan(span, result.resp, r.requestError(ErrorPhaseStatus, result.resp, fmt.Errorf("retried status code: %d", result.resp.StatusCode)))
+			}
+
+			if err := sleepContext(r.ctx, delay); err != nil {
+				return nil, r.processError(ErrorPhaseTransport, result.req, nil, fmt.Errorf("retry: %w", err), r.body)
+			}
+			continue
+		}
+
+		var err error
+		if result.err != nil {
+			err = r.processError(result.phase, result.req, nil, result.err, r.body)
+		}
+
+		r.responseCounter = result.counter
+		resp, err := r.handleResponse(result.req, result.resp, err)
+		endSpan(span, resp, err)
+
+		return resp, err
 	}
+}
 
-	req, err := http.NewRequestWithContext(r.ctx, r.method, r.path, bodyBuffer)
-	if err != nil {
-		return nil, r.processError(req, nil, err, r.body)
+// roundTrip builds the http.Request for a single attempt with the provided context, authenticates and sends it.
+// With hedging enabled, the attempt might be sent several times, the winning one is returned.
+// The error of the result is not processed yet.
+func (r *Request) roundTrip(ctx context.Context) attemptResult {
+	if r.hedgeMaxExtra > 0 {
+		return r.hedgedAttempt(ctx)
 	}
 
-	if r.options.Headers != nil {
-		for key, value := range r.options.Headers {
-			req.Header.Set(key, value)
+	return r.attempt(ctx)
+}
+
+// attemptResult is the outcome of sending the request once.
+// The error is not processed yet, so the errors of discarded hedged attempts and retried attempts are not reported.
+type attemptResult struct {
+	req   *http.Request
+	resp  *http.Response
+	phase ErrorPhase
+	err   error
+	// counter counts the response body when metrics are enabled.
+	counter *countingBody
+}
+
+func (r *Request) attempt(ctx context.Context) attemptResult {
+	var labels MetricLabels
+	limiter := r.client.rateLimiter
+	if limiter != nil {
+		labels = r.metricLabels()
+		if err := limiter.wait(ctx, labels.Host, labels.Route); err != nil {
+			return attemptResult{phase: ErrorPhaseRejected, err: err}
 		}
 	}
 
-	if r.options.BodyUnmarshaler != nil {
-		beforeRequestHooks = append(beforeRequestHooks, r.options.BodyUnmarshaler.OnRequestReady)
+	req, authHeaders, err := r.prepareRequest(ctx)
+	if err != nil {
+		return attemptResult{req: req, phase: ErrorPhaseRequest, err: err}
 	}
-	if r.options.OnRequestReady != nil {
-		beforeRequestHooks = append(beforeRequestHooks, r.options.OnRequestReady)
+
+	r.trackUploadProgress(req)
+
+	resp, err := r.client.do(req, r.options, authHeaders)
+	if err != nil {
+		return attemptResult{req: req, phase: ErrorPhaseTransport, err: err}
 	}
-	for _, beforeHook := range beforeRequestHooks {
-		if err := beforeHook(req); err != nil {
-			return nil, r.processError(req, nil, fmt.Errorf("on request ready hook: %w", err), r.body)
+
+	if limiter != nil {
+		limiter.observe(labels.Host, resp)
+	}
+
+	r.limitResponseBody(resp)
+
+	var counter *countingBody
+	if r.client.metrics != nil && resp.Body != nil {
+		counter = &countingBody{ReadCloser: resp.Body}
+		resp.Body = counter
+	}
+
+	return attemptResult{req: req, resp: resp, counter: counter}
+}
+
+// prepareRequest builds the http.Request and adds the trace propagation headers, the credentials and the signature.
+// authHeaders are the names of the headers set or changed by the Authenticator.
+func (r *Request) prepareRequest(ctx context.Context) (req *http.Request, authHeaders []string, err error) {
+	req, err = r.buildRequest(ctx)
+	if err != nil {
+		return req, nil, err
+	}
+
+	if tracer := r.client.tracer; tracer != nil {
+		tracer.Inject(ctx, req.Header)
+	}
+
+	if authenticator := r.options.Authenticator; authenticator != nil {
+		before := req.Header.Clone()
+		if err := authenticator.Authenticate(req); err != nil {
+			return req, nil, fmt.Errorf("authentication: %w", err)
+		}
+		authHeaders = changedHeaders(before, req.Header)
+	}
+
+	// Signing runs last, so the signature covers the final headers.
+	if signer := r.options.Signer; signer != nil {
+		if err := signer.Sign(req, signingBody(req, r.marshaledBody)); err != nil {
+			return req, nil, fmt.Errorf("signing: %w", err)
 		}
 	}
 
-	resp, err := r.client.do(req)
+	return req, authHeaders, nil
+}
+
+// changedHeaders returns the names of the headers that are added or changed in after.
+func changedHeaders(before, after http.Header) []string {
+	var names []string
+	for name, values := range after {
+		if strings.Join(values, ",") != strings.Join(before[name], ",") {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// handleResponse runs the response hooks, validates the status code and unmarshals the body of the final attempt.
+func (r *Request) handleResponse(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	}
 
 	// Ensure the response body is closed to prevent resource leaks.
 	defer func() {
@@ -197,46 +491,181 @@ func (r *Request) Do() (*http.Response, error) {
 		}
 	}()
 
-	if err != nil {
-		return nil, r.processError(req, nil, err, r.body)
-	}
-
 	var afterRequestHooks []OnResponseReadyHook
 	if r.options.OnResponseReady != nil {
 		afterRequestHooks = append(afterRequestHooks, r.options.OnResponseReady)
 	}
 	for _, afterHook := range afterRequestHooks {
 		if err := afterHook(resp); err != nil {
-			return resp, r.processError(req, resp, fmt.Errorf("on response ready hook: %w", err), r.body)
+			return resp, r.processError(ErrorPhaseResponse, req, resp, fmt.Errorf("on response ready hook: %w", err), r.body)
 		}
 	}
 
 	if !IsSuccessResponse(resp) {
 		err = fmt.Errorf("%s:%d", resp.Status, resp.StatusCode)
-		return resp, r.processError(req, resp, err, r.body)
+		return resp, r.processError(ErrorPhaseStatus, req, resp, err, r.body)
 	}
 
+	r.trackDownloadProgress(resp)
+
 	if r.unmarshalResult {
 		if r.options.BodyUnmarshaler != nil {
 			if err := r.options.BodyUnmarshaler.Unmarshal(r.unmarshalResultTo, resp.Body); err != nil {
-				return resp, r.processError(req, resp, fmt.Errorf("body unmarshaling: %w", err), r.body)
+				return resp, r.processError(ErrorPhaseUnmarshal, req, resp, fmt.Errorf("body unmarshaling: %w", err), r.body)
 			}
 		} else {
-			return resp, r.processError(req, resp, errors.New("result destination is provided but body unmarshaler is not set"), r.body)
+			return resp, r.processError(ErrorPhaseUnmarshal, req, resp, errors.New("result destination is provided but body unmarshaler is not set"), r.body)
 		}
 	}
 
 	return resp, nil
 }
 
-func (r *Request) processError(req *http.Request, resp *http.Response, err error, body interface{}) error {
+func (r *Request) retryUnauthorized(req *http.Request, resp *http.Response) bool {
+	authenticator := r.options.Authenticator
+	if authenticator == nil || resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	return authenticator.HandleUnauthorized(req, resp)
+}
+
+// url returns the request URL with the path placeholders replaced by the path params.
+func (r *Request) url() string {
+	if r.urlOverride != "" {
+		return r.urlOverride
+	}
+
+	if len(r.pathParams) == 0 {
+		return r.path
+	}
+
+	replacements := make([]string, 0, len(r.pathParams)*2)
+	for name, value := range r.pathParams {
+		replacements = append(replacements, "{"+name+"}", url.PathEscape(value))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(r.path)
+}
+
+// urlTemplate returns the request URL before the path params are applied, masked with the Redactor of the request.
+func (r *Request) urlTemplate() string {
+	// The braces of the placeholders are escaped by url.URL, they are restored to keep the template readable.
+	return strings.NewReplacer("%7B", "{", "%7D", "}").Replace(r.options.redactor().RedactURLString(r.path))
+}
+
+// routeTemplate returns the path of the request URL before the path params are applied, e.g. "/users/{id}".
+func (r *Request) routeTemplate() string {
+	template, err := url.Parse(r.path)
+	if err != nil {
+		return ""
+	}
+
+	if template.Path == "" {
+		return "/"
+	}
+
+	return template.Path
+}
+
+// marshalBody marshals the body with the configured BodyMarshaler.
+// The result is cached, so the request can be built multiple times (e.g. ToCurl followed by Do) without consuming the original body again.
+func (r *Request) marshalBody() ([]byte, error) {
+	if r.bodyMarshaled || r.body == nil {
+		return r.marshaledBody, nil
+	}
+
+	bodyMarshaler := r.options.BodyMarshaler
+	if bodyMarshaler == nil {
+		return nil, errors.New("body marshaler is not set")
+	}
+
+	// TODO: consider using sync.Pool to reuse buffers for the request body. Might be beneficial for performance in high-load scenarios.
+	bodyBuffer := &bytes.Buffer{}
+	if err := bodyMarshaler.Marshal(r.body, bodyBuffer); err != nil {
+		return nil, fmt.Errorf("body marshaling: %w", err)
+	}
+
+	r.marshaledBody = bodyBuffer.Bytes()
+	r.bodyMarshaled = true
+
+	return r.marshaledBody, nil
+}
+
+// buildRequest creates the http.Request with the marshaled body and headers and runs all before request hooks.
+// The returned request might be non-nil even if the error is returned, so it can be used for error reporting.
+func (r *Request) buildRequest(ctx context.Context) (*http.Request, error) {
+	var beforeRequestHooks []OnRequestReadyHook
+
+	body, err := r.marshalBody()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.body != nil {
+		beforeRequestHooks = append(beforeRequestHooks, r.options.BodyMarshaler.OnRequestReady)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url(), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.options.Headers != nil {
+		for key, value := range r.options.Headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	if key := r.idempotencyKeyInUse; key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	} else if r.idempotencyKey != "" {
+		req.Header.Set(HeaderIdempotencyKey, r.idempotencyKey)
+	}
+
+	if r.options.BodyUnmarshaler != nil {
+		beforeRequestHooks = append(beforeRequestHooks, r.options.BodyUnmarshaler.OnRequestReady)
+	}
+	if r.options.OnRequestReady != nil {
+		beforeRequestHooks = append(beforeRequestHooks, r.options.OnRequestReady)
+	}
+	for _, beforeHook := range beforeRequestHooks {
+		if err := beforeHook(req); err != nil {
+			return req, fmt.Errorf("on request ready hook: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+func (r *Request) processError(phase ErrorPhase, req *http.Request, resp *http.Response, err error, body interface{}) error {
+	err = r.requestError(phase, resp, err)
+
 	if r.options.StackTraceEnabled {
 		err = enrichErrorWithStackTrace(err)
 	}
 
 	for _, hook := range r.options.OnErrorHooks {
-		hook(req, resp, err, body)
+		hook(req, resp, err, body, r.options.redactor())
 	}
 
 	return err
 }
+
+// requestError wraps the error into a RequestError with the redacted URL, without running the error hooks.
+func (r *Request) requestError(phase ErrorPhase, resp *http.Response, err error) *RequestError {
+	// Transport errors carry the full request URL, which may contain secrets in the query.
+	err = r.options.redactor().RedactError(err)
+
+	requestErr := &RequestError{
+		Phase:  phase,
+		Method: r.method,
+		URL:    r.options.redactor().RedactURLString(r.url()),
+		Err:    err,
+	}
+	if resp != nil {
+		requestErr.StatusCode = resp.StatusCode
+	}
+
+	return requestErr
+}
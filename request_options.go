@@ -1,28 +1,50 @@
 package httpreqx
 
 import (
+	"fmt"
 	"net/http"
 )
 
 type RequestOptions struct {
-	BodyMarshaler     BodyMarshaler
-	BodyUnmarshaler   BodyUnmarshaler
-	Headers           map[string]string
-	OnRequestReady    OnRequestReadyHook
-	OnResponseReady   OnResponseReadyHook
-	OnErrorHooks      []onErrorHook
-	StackTraceEnabled bool
+	BodyMarshaler   BodyMarshaler
+	BodyUnmarshaler BodyUnmarshaler
+	Headers         map[string]string
+	OnRequestReady  OnRequestReadyHook
+	OnResponseReady OnResponseReadyHook
+	OnTimings       OnTimingsHook
+	// OnUploadProgress and OnDownloadProgress receive the progress of the request and response bodies, throttled to 10 calls per second.
+	OnUploadProgress   UploadProgressHook
+	OnDownloadProgress DownloadProgressHook
+	OnErrorHooks       []onErrorHook
+	StackTraceEnabled  bool
+	Redactor           *Redactor
+	Authenticator      Authenticator
+	Signer             Signer
+	RetryPolicy        *RetryPolicy
+	// MaxResponseBodySize limits the number of response body bytes read, 0 means no limit.
+	MaxResponseBodySize int64
+	// AutoIdempotencyKey enables generating the Idempotency-Key header for POST and PATCH requests.
+	AutoIdempotencyKey bool
 }
 
 func (o *RequestOptions) Clone() *RequestOptions {
 	clone := &RequestOptions{
-		BodyMarshaler:     o.BodyMarshaler,
-		BodyUnmarshaler:   o.BodyUnmarshaler,
-		Headers:           make(map[string]string),
-		OnRequestReady:    o.OnRequestReady,
-		OnResponseReady:   o.OnResponseReady,
-		OnErrorHooks:      append([]onErrorHook{}, o.OnErrorHooks...),
-		StackTraceEnabled: o.StackTraceEnabled,
+		BodyMarshaler:       o.BodyMarshaler,
+		BodyUnmarshaler:     o.BodyUnmarshaler,
+		Headers:             make(map[string]string),
+		OnRequestReady:      o.OnRequestReady,
+		OnResponseReady:     o.OnResponseReady,
+		OnTimings:           o.OnTimings,
+		OnUploadProgress:    o.OnUploadProgress,
+		OnDownloadProgress:  o.OnDownloadProgress,
+		OnErrorHooks:        append([]onErrorHook{}, o.OnErrorHooks...),
+		StackTraceEnabled:   o.StackTraceEnabled,
+		Redactor:            o.Redactor,
+		Authenticator:       o.Authenticator,
+		Signer:              o.Signer,
+		RetryPolicy:         o.RetryPolicy,
+		MaxResponseBodySize: o.MaxResponseBodySize,
+		AutoIdempotencyKey:  o.AutoIdempotencyKey,
 	}
 
 	for k, v := range o.Headers {
@@ -62,16 +84,89 @@ func (o *RequestOptions) SetOnResponseReady(onResponseReady OnResponseReadyHook)
 	o.OnResponseReady = onResponseReady
 }
 
+func (o *RequestOptions) SetOnTimings(onTimings OnTimingsHook) {
+	o.OnTimings = onTimings
+}
+
+func (o *RequestOptions) SetOnUploadProgress(onUploadProgress UploadProgressHook) {
+	o.OnUploadProgress = onUploadProgress
+}
+
+func (o *RequestOptions) SetOnDownloadProgress(onDownloadProgress DownloadProgressHook) {
+	o.OnDownloadProgress = onDownloadProgress
+}
+
 func (o *RequestOptions) SetDumpOnError() {
 	o.SetStackTraceEnabled(true)
 	o.OnErrorHooks = make([]onErrorHook, 0)
-	o.OnErrorHooks = append(o.OnErrorHooks, func(req *http.Request, resp *http.Response, _ error, body interface{}) {
-		dumpRequest(req)
-		dumpResponse(resp)
-		dumpBody(body)
+	o.OnErrorHooks = append(o.OnErrorHooks, func(req *http.Request, resp *http.Response, _ error, body interface{}, redactor *Redactor) {
+		dumpRequest(req, redactor)
+		dumpResponse(resp, redactor)
+		dumpBody(body, redactor)
+	})
+}
+
+func (o *RequestOptions) SetCurlOnError() {
+	o.OnErrorHooks = make([]onErrorHook, 0)
+	o.OnErrorHooks = append(o.OnErrorHooks, func(req *http.Request, _ *http.Response, err error, _ interface{}, redactor *Redactor) {
+		// The error is already masked by the Request, RedactError covers errors of custom hooks carrying the URL.
+		fmt.Printf("ERROR: %s\n", redactor.RedactError(err))
+
+		if req == nil {
+			return
+		}
+
+		fmt.Printf("Request as curl:\n%s\n", curlCommand(req, redactor))
 	})
 }
 
 func (o *RequestOptions) SetStackTraceEnabled(enabled bool) {
 	o.StackTraceEnabled = enabled
 }
+
+func (o *RequestOptions) SetRedactor(redactor *Redactor) {
+	o.Redactor = redactor
+}
+
+func (o *RequestOptions) SetAuth(authenticator Authenticator) {
+	o.Authenticator = authenticator
+}
+
+func (o *RequestOptions) SetBasicAuth(username, password string) {
+	o.SetAuth(NewBasicAuth(username, password))
+}
+
+func (o *RequestOptions) SetBearerToken(tokenOrFunc interface{}) {
+	o.SetAuth(NewBearerToken(tokenOrFunc))
+}
+
+func (o *RequestOptions) SetAPIKey(location APIKeyLocation, name, value string) {
+	o.SetAuth(NewAPIKey(location, name, value))
+}
+
+// redactor returns the Redactor to apply to the request. Custom API key names are not known to the Redactor,
+// they are added to a copy of it when the request runs, so the key stays masked whatever the order of SetAPIKey and SetRedactor is.
+func (o *RequestOptions) redactor() *Redactor {
+	apiKey, ok := o.Authenticator.(*apiKeyAuth)
+	if o.Redactor == nil || !ok {
+		return o.Redactor
+	}
+
+	return o.Redactor.withAPIKey(apiKey.location, apiKey.name)
+}
+
+func (o *RequestOptions) SetSigner(signer Signer) {
+	o.Signer = signer
+}
+
+func (o *RequestOptions) SetRetryPolicy(policy *RetryPolicy) {
+	o.RetryPolicy = policy
+}
+
+func (o *RequestOptions) SetAutoIdempotencyKey(enabled bool) {
+	o.AutoIdempotencyKey = enabled
+}
+
+func (o *RequestOptions) SetMaxResponseBodySize(size int64) {
+	o.MaxResponseBodySize = size
+}
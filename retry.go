@@ -0,0 +1,192 @@
+package httpreqx
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = 100 * time.Millisecond
+	defaultRetryMaxDelay    = 5 * time.Second
+)
+
+// RetryCondition decides whether an attempt is retried. err is the transport error, resp is nil in this case.
+type RetryCondition func(resp *http.Response, err error) bool
+
+// RetryPolicy repeats failed attempts of a request with exponential backoff and full jitter, set with SetRetryPolicy.
+// By default transport errors and the 429, 502, 503 and 504 status codes are retried, the Retry-After header of the response is respected.
+// Only requests that are safe to repeat are retried: the GET, HEAD, OPTIONS, TRACE, PUT and DELETE methods,
+// and any method, e.g. POST and PATCH, when the request has an idempotency key (see Request.SetIdempotencyKey and SetAutoIdempotencyKey).
+// The same idempotency key is sent with every attempt.
+type RetryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	condition   RetryCondition
+}
+
+// NewRetryPolicy creates a RetryPolicy making at most maxAttempts attempts (including the first one), with the backoff starting at 100ms, capped at 5s.
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		maxAttempts: maxAttempts,
+		baseDelay:   defaultRetryBaseDelay,
+		maxDelay:    defaultRetryMaxDelay,
+		condition:   defaultRetryCondition,
+	}
+}
+
+// SetBackoff sets the base delay, doubled with every retry, and the maximum delay. Retry-After values above the maximum delay stop the retries.
+func (p *RetryPolicy) SetBackoff(base, max time.Duration) *RetryPolicy {
+	p.baseDelay = base
+	p.maxDelay = max
+	return p
+}
+
+// SetRetryCondition replaces the default decision which attempts are retried.
+func (p *RetryPolicy) SetRetryCondition(condition RetryCondition) *RetryPolicy {
+	p.condition = condition
+	return p
+}
+
+func defaultRetryCondition(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// delay returns the time to wait before the retry with the number (starting with 1), or false if no more retries are allowed.
+func (p *RetryPolicy) delay(retry int, resp *http.Response) (time.Duration, bool) {
+	if retry >= p.maxAttempts {
+		return 0, false
+	}
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if retryAfter > p.maxDelay {
+				return 0, false
+			}
+			if retryAfter < 0 {
+				retryAfter = 0
+			}
+			return retryAfter, true
+		}
+	}
+
+	backoff := p.maxDelay
+	if shift := retry - 1; shift < 30 {
+		if exponential := p.baseDelay << shift; exponential > 0 && exponential < p.maxDelay {
+			backoff = exponential
+		}
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(backoff)+1))
+	if err != nil {
+		return backoff, true
+	}
+
+	return time.Duration(jitter.Int64()), true
+}
+
+// retryDelay returns the time to wait before the retry with the number, or false if the attempt is final.
+func (r *Request) retryDelay(retry int, result attemptResult) (time.Duration, bool) {
+	policy := r.options.RetryPolicy
+	if policy == nil || !r.retryable() {
+		return 0, false
+	}
+
+	// Only transport errors and received responses are retried, errors of the request itself would fail again.
+	if result.err != nil && result.phase != ErrorPhaseTransport {
+		return 0, false
+	}
+	if result.err != nil && (r.ctx.Err() != nil || errors.Is(result.err, context.Canceled)) {
+		return 0, false
+	}
+	if !policy.condition(result.resp, result.err) {
+		return 0, false
+	}
+
+	return policy.delay(retry, result.resp)
+}
+
+func (r *Request) retryable() bool {
+	if r.idempotencyKeyInUse != "" {
+		return true
+	}
+
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetIdempotencyKey sets the Idempotency-Key header sent with every attempt of the request. It allows retrying POST and PATCH requests.
+func (r *Request) SetIdempotencyKey(key string) *Request {
+	r.idempotencyKey = key
+	return r
+}
+
+// resolveIdempotencyKey returns the key for all attempts of a Do call: the one set with SetIdempotencyKey,
+// or a new UUID for POST and PATCH requests when SetAutoIdempotencyKey is enabled.
+func (r *Request) resolveIdempotencyKey() string {
+	if r.idempotencyKey != "" {
+		return r.idempotencyKey
+	}
+	if !r.options.AutoIdempotencyKey || (r.method != http.MethodPost && r.method != http.MethodPatch) {
+		return ""
+	}
+
+	key, err := newUUID()
+	if err != nil {
+		return ""
+	}
+
+	return key
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return "", err
+	}
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
+}
+
+// sleepContext waits for the duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,160 @@
+package httpreqx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	r := require.New(t)
+
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		keys = append(keys, req.Header.Get(HeaderIdempotencyKey))
+		n := len(keys)
+		mu.Unlock()
+
+		switch req.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/retry-after":
+			if n == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/retry-after-long":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		case "/bad-request":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHttpClient().
+		SetBodyMarshaler(NewJSONBodyMarshaler()).
+		SetRetryPolicy(NewRetryPolicy(3).SetBackoff(time.Millisecond, 10*time.Millisecond))
+	ctx := context.Background()
+
+	reset := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sent := keys
+		keys = nil
+		return sent
+	}
+
+	t.Run("GET is retried until it succeeds", func(t *testing.T) {
+		reset()
+
+		resp, err := client.NewGetRequest(ctx, server.URL+"/flaky").Do()
+		r.NoError(err)
+		r.Equal(http.StatusOK, resp.StatusCode)
+		r.Len(reset(), 3)
+	})
+
+	t.Run("POST without idempotency key is not retried", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewPostRequest(ctx, server.URL+"/flaky", map[string]string{"id": "1"}).Do()
+		r.Error(err)
+		r.Equal([]string{""}, reset())
+	})
+
+	t.Run("Explicit key is reused across attempts", func(t *testing.T) {
+		reset()
+
+		resp, err := client.NewPostRequest(ctx, server.URL+"/flaky", map[string]string{"id": "1"}).
+			SetIdempotencyKey("order-42").
+			Do()
+		r.NoError(err)
+		r.Equal(http.StatusOK, resp.StatusCode)
+		r.Equal([]string{"order-42", "order-42", "order-42"}, reset())
+	})
+
+	t.Run("Automatic key is generated once per Do call", func(t *testing.T) {
+		reset()
+
+		request := client.NewPatchRequest(ctx, server.URL+"/flaky", map[string]string{"id": "1"}).SetAutoIdempotencyKey(true)
+		_, err := request.Do()
+		r.NoError(err)
+
+		sent := reset()
+		r.Len(sent, 3)
+		r.Regexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, sent[0])
+		r.Equal(sent[0], sent[1])
+		r.Equal(sent[0], sent[2])
+
+		_, err = request.Do()
+		r.NoError(err)
+		r.NotEqual(sent[0], reset()[0])
+	})
+
+	t.Run("Automatic key is not added to GET requests", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/").SetAutoIdempotencyKey(true).Do()
+		r.NoError(err)
+		r.Equal([]string{""}, reset())
+	})
+
+	t.Run("Retry-After is respected", func(t *testing.T) {
+		reset()
+
+		policy := NewRetryPolicy(3).SetBackoff(time.Millisecond, 2*time.Second)
+		start := time.Now()
+		resp, err := client.NewGetRequest(ctx, server.URL+"/retry-after").SetRetryPolicy(policy).Do()
+		r.NoError(err)
+		r.Equal(http.StatusOK, resp.StatusCode)
+		r.GreaterOrEqual(time.Since(start), time.Second)
+		r.Len(reset(), 2)
+	})
+
+	t.Run("Retry-After above the maximum delay stops retries", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/retry-after-long").Do()
+		r.Error(err)
+
+		var requestErr *RequestError
+		r.ErrorAs(err, &requestErr)
+		r.Equal(http.StatusTooManyRequests, requestErr.StatusCode)
+		r.Len(reset(), 1)
+	})
+
+	t.Run("Non-retryable status is returned immediately", func(t *testing.T) {
+		reset()
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/bad-request").Do()
+		r.Error(err)
+		r.Len(reset(), 1)
+	})
+
+	t.Run("Canceled context stops waiting", func(t *testing.T) {
+		reset()
+
+		policy := NewRetryPolicy(3).SetBackoff(time.Hour, time.Hour)
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		_, err := client.NewGetRequest(ctx, server.URL+"/flaky").SetRetryPolicy(policy).Do()
+		r.ErrorIs(err, context.DeadlineExceeded)
+		r.Len(reset(), 1)
+	})
+}
@@ -0,0 +1,255 @@
+package httpreqx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs outgoing requests, e.g. with AWS Signature Version 4.
+// Sign is called for every attempt as the very last step before the request is sent,
+// after all headers are set, the before request hooks are executed and the Authenticator added the credentials.
+// body is the buffered marshaled body, it is empty for requests without a body and nil when the body is streamed
+// (a before request hook replaced req.Body without setting req.GetBody), in which case it can't be read in advance.
+// Sign must not consume req.Body. Implementations must be safe for concurrent use.
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// signingBody returns the body passed to the Signer.
+func signingBody(req *http.Request, marshaledBody []byte) []byte {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil
+	}
+	if marshaledBody == nil {
+		return []byte{}
+	}
+
+	return marshaledBody
+}
+
+// CanonicalRequestBuilder builds the canonical form of a request that is signed by the HMACSigner.
+// The canonical request is made of the following lines, joined by "\n", each of them can be excluded:
+// - the method
+// - the escaped path ("/" if empty)
+// - the query, sorted by name and value, with RFC 3986 escaping
+// - one "name:value" line per signed header, with lowercase names in alphabetical order and trimmed values (missing headers have an empty value)
+// - the hex encoded SHA-256 digest of the buffered body, or the body itself (see SetRawBody)
+type CanonicalRequestBuilder struct {
+	method     bool
+	path       bool
+	query      bool
+	bodyDigest bool
+	rawBody    bool
+	headers    []string
+}
+
+// NewCanonicalRequestBuilder creates a CanonicalRequestBuilder including the method, path, query and body digest, without headers.
+func NewCanonicalRequestBuilder() *CanonicalRequestBuilder {
+	return &CanonicalRequestBuilder{
+		method:     true,
+		path:       true,
+		query:      true,
+		bodyDigest: true,
+	}
+}
+
+// SetMethod configures whether the method is part of the canonical request.
+func (b *CanonicalRequestBuilder) SetMethod(enabled bool) *CanonicalRequestBuilder {
+	b.method = enabled
+	return b
+}
+
+// SetPath configures whether the path is part of the canonical request.
+func (b *CanonicalRequestBuilder) SetPath(enabled bool) *CanonicalRequestBuilder {
+	b.path = enabled
+	return b
+}
+
+// SetQuery configures whether the query is part of the canonical request.
+func (b *CanonicalRequestBuilder) SetQuery(enabled bool) *CanonicalRequestBuilder {
+	b.query = enabled
+	return b
+}
+
+// SetBodyDigest configures whether the body digest is part of the canonical request.
+// Signing a streamed body fails when it is enabled.
+func (b *CanonicalRequestBuilder) SetBodyDigest(enabled bool) *CanonicalRequestBuilder {
+	b.bodyDigest = enabled
+	return b
+}
+
+// SetRawBody configures whether the buffered body itself is part of the canonical request instead of its digest,
+// e.g. for GitHub style signatures (X-Hub-Signature-256) which are the HMAC of the raw payload.
+// Signing a streamed body fails when it is enabled.
+func (b *CanonicalRequestBuilder) SetRawBody(enabled bool) *CanonicalRequestBuilder {
+	b.rawBody = enabled
+	return b
+}
+
+// AddHeaders adds headers to the canonical request.
+func (b *CanonicalRequestBuilder) AddHeaders(names ...string) *CanonicalRequestBuilder {
+	for _, name := range names {
+		b.headers = append(b.headers, strings.ToLower(name))
+	}
+	return b
+}
+
+// Build returns the canonical request. body is the buffered body, as passed to Signer.Sign.
+func (b *CanonicalRequestBuilder) Build(req *http.Request, body []byte) (string, error) {
+	return b.build(req, body, nil)
+}
+
+// build returns the canonical request, extraHeaders are signed in addition to the configured ones.
+func (b *CanonicalRequestBuilder) build(req *http.Request, body []byte, extraHeaders []string) (string, error) {
+	var lines []string
+
+	if b.method {
+		lines = append(lines, req.Method)
+	}
+	if b.path {
+		path := req.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		lines = append(lines, path)
+	}
+	if b.query {
+		lines = append(lines, sigV4CanonicalQuery(req.URL.Query()))
+	}
+
+	seen := make(map[string]struct{})
+	var headers []string
+	for _, name := range append(append([]string{}, b.headers...), extraHeaders...) {
+		name = strings.ToLower(name)
+		if _, exists := seen[name]; exists || name == "" {
+			continue
+		}
+		seen[name] = struct{}{}
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		lines = append(lines, name+":"+strings.Join(strings.Fields(value), " "))
+	}
+
+	if b.rawBody {
+		if body == nil {
+			return "", errors.New("raw body requires a buffered body")
+		}
+		lines = append(lines, string(body))
+	} else if b.bodyDigest {
+		if body == nil {
+			return "", errors.New("body digest requires a buffered body")
+		}
+		lines = append(lines, hashSHA256(body))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Timestamp"
+	defaultNonceHeader     = "X-Nonce"
+)
+
+// HMACSigner is a Signer that adds the hex encoded HMAC-SHA256 of the canonical request to the signature header.
+// Before signing, the current Unix time (in seconds) and a random nonce are set to the timestamp and nonce headers,
+// both headers are always part of the canonical request, so the receiver can reject replayed requests.
+type HMACSigner struct {
+	secret          []byte
+	builder         *CanonicalRequestBuilder
+	signatureHeader string
+	signaturePrefix string
+	timestampHeader string
+	nonceHeader     string
+	now             func() time.Time
+	nonce           func() (string, error)
+}
+
+// NewHMACSigner creates an HMACSigner with the secret and the default CanonicalRequestBuilder.
+// The signature, timestamp and nonce are sent in the X-Signature, X-Timestamp and X-Nonce headers.
+func NewHMACSigner(secret []byte) *HMACSigner {
+	return &HMACSigner{
+		secret:          secret,
+		builder:         NewCanonicalRequestBuilder(),
+		signatureHeader: defaultSignatureHeader,
+		timestampHeader: defaultTimestampHeader,
+		nonceHeader:     defaultNonceHeader,
+		now:             time.Now,
+		nonce:           randomNonce,
+	}
+}
+
+// SetCanonicalRequestBuilder sets the builder of the signed canonical request.
+func (s *HMACSigner) SetCanonicalRequestBuilder(builder *CanonicalRequestBuilder) *HMACSigner {
+	s.builder = builder
+	return s
+}
+
+// SetSignatureHeader sets the header with the signature. The prefix is prepended to the signature, e.g. "sha256=".
+func (s *HMACSigner) SetSignatureHeader(name, prefix string) *HMACSigner {
+	s.signatureHeader = name
+	s.signaturePrefix = prefix
+	return s
+}
+
+// SetTimestampHeader sets the header with the signing time. An empty name disables the timestamp.
+func (s *HMACSigner) SetTimestampHeader(name string) *HMACSigner {
+	s.timestampHeader = name
+	return s
+}
+
+// SetNonceHeader sets the header with the random nonce. An empty name disables the nonce.
+func (s *HMACSigner) SetNonceHeader(name string) *HMACSigner {
+	s.nonceHeader = name
+	return s
+}
+
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	if s.timestampHeader != "" {
+		req.Header.Set(s.timestampHeader, strconv.FormatInt(s.now().Unix(), 10))
+	}
+	if s.nonceHeader != "" {
+		nonce, err := s.nonce()
+		if err != nil {
+			return fmt.Errorf("nonce: %w", err)
+		}
+		req.Header.Set(s.nonceHeader, nonce)
+	}
+
+	canonicalRequest, err := s.builder.build(req, body, []string{s.timestampHeader, s.nonceHeader})
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(s.signatureHeader, s.signatureOf(canonicalRequest))
+	return nil
+}
+
+func (s *HMACSigner) signatureOf(canonicalRequest string) string {
+	return s.signaturePrefix + hex.EncodeToString(hmacSHA256(s.secret, []byte(canonicalRequest)))
+}
+
+func randomNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(nonce), nil
+}
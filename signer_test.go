@@ -0,0 +1,116 @@
+package httpreqx
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHMACSigner(secret string) *HMACSigner {
+	signer := NewHMACSigner([]byte(secret))
+	signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+	signer.nonce = func() (string, error) { return "abc123", nil }
+	return signer
+}
+
+func TestHMACSigner(t *testing.T) {
+	r := require.New(t)
+
+	newRequest := func(body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "https://partner.example.com/v1/orders?b=2&a=1", strings.NewReader(body))
+		r.NoError(err)
+		req.Header.Set(HeaderContentType, "application/json")
+		return req
+	}
+
+	t.Run("Canonical request", func(t *testing.T) {
+		req := newRequest(`{"id":1}`)
+		req.Header.Set("X-Nonce", "abc123")
+		req.Header.Set("X-Timestamp", "1700000000")
+
+		canonical, err := NewCanonicalRequestBuilder().
+			AddHeaders(HeaderContentType, "X-Timestamp", "X-Nonce").
+			Build(req, []byte(`{"id":1}`))
+		r.NoError(err)
+		r.Equal("POST\n/v1/orders\na=1&b=2\ncontent-type:application/json\nx-nonce:abc123\nx-timestamp:1700000000\n"+
+			"037c9214eef74cc3887f3a4f085b4e17d76280dafd273b0ee160c09c4ba1cfd4", canonical)
+
+		_, err = NewCanonicalRequestBuilder().Build(req, nil)
+		r.Error(err, "streamed bodies can't be digested")
+	})
+
+	t.Run("HMAC-SHA256 test vector (RFC 4231, test case 2)", func(t *testing.T) {
+		signer := NewHMACSigner([]byte("Jefe"))
+		r.Equal("5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", signer.signatureOf("what do ya want for nothing?"))
+	})
+
+	t.Run("Signs the canonical request with timestamp and nonce", func(t *testing.T) {
+		signer := newTestHMACSigner("secret")
+		signer.builder.AddHeaders(HeaderContentType)
+
+		req := newRequest(`{"id":1}`)
+		r.NoError(signer.Sign(req, []byte(`{"id":1}`)))
+
+		r.Equal("1700000000", req.Header.Get("X-Timestamp"))
+		r.Equal("abc123", req.Header.Get("X-Nonce"))
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte("POST\n/v1/orders\na=1&b=2\ncontent-type:application/json\nx-nonce:abc123\nx-timestamp:1700000000\n" +
+			"037c9214eef74cc3887f3a4f085b4e17d76280dafd273b0ee160c09c4ba1cfd4"))
+		r.Equal(hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Signature"))
+	})
+
+	t.Run("GitHub webhook test vector", func(t *testing.T) {
+		// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries#testing-the-webhook-payload-validation
+		signer := NewHMACSigner([]byte("It's a Secret to Everybody")).
+			SetCanonicalRequestBuilder(NewCanonicalRequestBuilder().SetMethod(false).SetPath(false).SetQuery(false).SetRawBody(true)).
+			SetSignatureHeader("X-Hub-Signature-256", "sha256=").
+			SetTimestampHeader("").
+			SetNonceHeader("")
+
+		req := newRequest("Hello, World!")
+		r.NoError(signer.Sign(req, []byte("Hello, World!")))
+
+		r.Empty(req.Header.Get("X-Timestamp"))
+		r.Empty(req.Header.Get("X-Nonce"))
+		r.Equal("sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17", req.Header.Get("X-Hub-Signature-256"))
+
+		_, err := NewCanonicalRequestBuilder().SetRawBody(true).Build(req, nil)
+		r.Error(err, "streamed bodies can't be signed")
+	})
+
+	t.Run("Signs the marshaled body and a fresh nonce per request", func(t *testing.T) {
+		var nonces []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			nonces = append(nonces, req.Header.Get("X-Nonce"))
+
+			canonical, _ := NewCanonicalRequestBuilder().AddHeaders("X-Timestamp", "X-Nonce").Build(req, body)
+			if req.Header.Get("X-Signature") != NewHMACSigner([]byte("secret")).signatureOf(canonical) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer server.Close()
+
+		client := NewHttpClient().
+			SetBodyMarshaler(NewJSONBodyMarshaler()).
+			SetSigner(NewHMACSigner([]byte("secret")))
+
+		for i := 0; i < 2; i++ {
+			_, err := client.NewPostRequest(context.Background(), server.URL+"/hooks?x=1", map[string]int{"id": i}).Do()
+			r.NoError(err)
+		}
+
+		r.Len(nonces, 2)
+		r.NotEqual(nonces[0], nonces[1])
+	})
+}
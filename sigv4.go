@@ -0,0 +1,310 @@
+package httpreqx
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm      = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat     = "20060102T150405Z"
+	sigV4DateFormat     = "20060102"
+	sigV4UnsignedBody   = "UNSIGNED-PAYLOAD"
+	sigV4MaxPresignTime = 7 * 24 * time.Hour
+
+	HeaderAmzDate          = "X-Amz-Date"
+	HeaderAmzContentSHA256 = "X-Amz-Content-Sha256"
+	HeaderAmzSecurityToken = "X-Amz-Security-Token"
+)
+
+// sigV4IgnoredHeaders are not signed, because they are commonly changed by proxies and tracing middleware on the way to the service.
+var sigV4IgnoredHeaders = map[string]struct{}{
+	HeaderAuthorization:   {},
+	HeaderUserAgent:       {},
+	"Expect":              {},
+	"X-Amzn-Trace-Id":     {},
+	HeaderTraceParent:     {},
+	HeaderTraceState:      {},
+	"Content-Length":      {},
+	"Transfer-Encoding":   {},
+	"Connection":          {},
+	"Proxy-Authorization": {},
+}
+
+// AWSCredentials are the credentials used to sign requests with SigV4.
+// SessionToken is only set for temporary credentials.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSCredentialsProvider supplies the credentials for every signed request.
+// It allows rotating credentials (e.g. assumed roles), implementations must be safe for concurrent use and should cache the credentials.
+type AWSCredentialsProvider interface {
+	Retrieve(ctx context.Context) (AWSCredentials, error)
+}
+
+// AWSCredentialsProviderFunc adapts a function to the AWSCredentialsProvider interface,
+// e.g. to wrap the credentials provider of the AWS SDK.
+type AWSCredentialsProviderFunc func(ctx context.Context) (AWSCredentials, error)
+
+func (f AWSCredentialsProviderFunc) Retrieve(ctx context.Context) (AWSCredentials, error) {
+	return f(ctx)
+}
+
+// NewStaticAWSCredentials creates an AWSCredentialsProvider that always returns the same credentials.
+func NewStaticAWSCredentials(accessKeyID, secretAccessKey, sessionToken string) AWSCredentialsProvider {
+	credentials := AWSCredentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, SessionToken: sessionToken}
+	return AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		return credentials, nil
+	})
+}
+
+// SigV4Signer is a Signer implementing AWS Signature Version 4.
+// The payload hash is computed from the buffered body, streamed bodies are sent with UNSIGNED-PAYLOAD.
+// For the "s3" service the X-Amz-Content-Sha256 header is always set and the path is not escaped a second time, as S3 requires.
+type SigV4Signer struct {
+	region          string
+	service         string
+	credentials     AWSCredentialsProvider
+	unsignedPayload bool
+	now             func() time.Time
+}
+
+// NewSigV4Signer creates a SigV4Signer for the region and service, e.g. "us-east-1" and "s3".
+func NewSigV4Signer(region, service string, credentials AWSCredentialsProvider) *SigV4Signer {
+	return &SigV4Signer{
+		region:      region,
+		service:     service,
+		credentials: credentials,
+		now:         time.Now,
+	}
+}
+
+// SetUnsignedPayload configures sending UNSIGNED-PAYLOAD instead of the body hash, even when the body is buffered.
+// It is supported by S3 and saves hashing large uploads sent over TLS.
+func (s *SigV4Signer) SetUnsignedPayload(enabled bool) *SigV4Signer {
+	s.unsignedPayload = enabled
+	return s
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to the request.
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	credentials, err := s.credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("sigv4 credentials: %w", err)
+	}
+
+	signingTime := s.now().UTC()
+
+	payloadHash := sigV4UnsignedBody
+	if body != nil && !s.unsignedPayload {
+		payloadHash = hashSHA256(body)
+	}
+
+	req.Header.Set(HeaderAmzDate, signingTime.Format(sigV4TimeFormat))
+	if credentials.SessionToken != "" {
+		req.Header.Set(HeaderAmzSecurityToken, credentials.SessionToken)
+	} else {
+		req.Header.Del(HeaderAmzSecurityToken)
+	}
+	if s.service == "s3" || payloadHash == sigV4UnsignedBody {
+		req.Header.Set(HeaderAmzContentSHA256, payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := sigV4CanonicalHeaders(req)
+	signature := s.signature(credentials, signingTime, req, req.URL.Query(), signedHeaders, canonicalHeaders, payloadHash)
+
+	req.Header.Set(HeaderAuthorization, fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyID, s.scope(signingTime), signedHeaders, signature,
+	))
+
+	return nil
+}
+
+// Presign returns the URL of the request with the signature in the query, valid for the given duration (at most 7 days).
+// The request is not modified, its headers (except the ignored ones) must be sent along with the presigned URL.
+// The payload is always UNSIGNED-PAYLOAD.
+func (s *SigV4Signer) Presign(req *http.Request, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > sigV4MaxPresignTime {
+		return "", errors.New("sigv4 presign: expires must be between 1 second and 7 days")
+	}
+
+	credentials, err := s.credentials.Retrieve(req.Context())
+	if err != nil {
+		return "", fmt.Errorf("sigv4 credentials: %w", err)
+	}
+
+	signingTime := s.now().UTC()
+	signedHeaders, canonicalHeaders := sigV4CanonicalHeaders(req)
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", credentials.AccessKeyID+"/"+s.scope(signingTime))
+	query.Set("X-Amz-Date", signingTime.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+	if credentials.SessionToken != "" {
+		query.Set(HeaderAmzSecurityToken, credentials.SessionToken)
+	}
+
+	signature := s.signature(credentials, signingTime, req, query, signedHeaders, canonicalHeaders, sigV4UnsignedBody)
+
+	presigned := *req.URL
+	presigned.RawQuery = sigV4CanonicalQuery(query) + "&X-Amz-Signature=" + signature
+
+	return presigned.String(), nil
+}
+
+func (s *SigV4Signer) scope(signingTime time.Time) string {
+	return strings.Join([]string{signingTime.Format(sigV4DateFormat), s.region, s.service, "aws4_request"}, "/")
+}
+
+func (s *SigV4Signer) signature(credentials AWSCredentials, signingTime time.Time, req *http.Request, query url.Values, signedHeaders, canonicalHeaders, payloadHash string) string {
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalPath(req.URL),
+		sigV4CanonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		signingTime.Format(sigV4TimeFormat),
+		s.scope(signingTime),
+		hashSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), []byte(signingTime.Format(sigV4DateFormat)))
+	key = hmacSHA256(key, []byte(s.region))
+	key = hmacSHA256(key, []byte(s.service))
+	key = hmacSHA256(key, []byte("aws4_request"))
+
+	return hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+}
+
+// canonicalPath returns the escaped path. All services except S3 expect every segment to be escaped twice.
+func (s *SigV4Signer) canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if s.service == "s3" {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// sigV4CanonicalHeaders returns the signed header names and the canonical headers block, including the Host header.
+func sigV4CanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = stripDefaultPort(req.URL.Scheme, host)
+
+	values := map[string]string{"host": host}
+	names := []string{"host"}
+	for name, headerValues := range req.Header {
+		if _, ignored := sigV4IgnoredHeaders[http.CanonicalHeaderKey(name)]; ignored {
+			continue
+		}
+
+		lowerName := strings.ToLower(name)
+		if _, exists := values[lowerName]; exists {
+			continue
+		}
+
+		trimmed := make([]string, len(headerValues))
+		for i, value := range headerValues {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[lowerName] = strings.Join(trimmed, ",")
+		names = append(names, lowerName)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name)
+		canonical.WriteByte(':')
+		canonical.WriteString(values[name])
+		canonical.WriteByte('\n')
+	}
+
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// sigV4CanonicalQuery encodes the query sorted by name and value, with RFC 3986 escaping.
+func sigV4CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	escaped := make(map[string][]string, len(query))
+	for name, values := range query {
+		escapedName := sigV4Escape(name)
+		escapedValues := make([]string, len(values))
+		for i, value := range values {
+			escapedValues[i] = sigV4Escape(value)
+		}
+		sort.Strings(escapedValues)
+
+		names = append(names, escapedName)
+		escaped[escapedName] = escapedValues
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range escaped[name] {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape escapes everything except the RFC 3986 unreserved characters.
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func stripDefaultPort(scheme, host string) string {
+	if scheme == "http" && strings.HasSuffix(host, ":80") {
+		return strings.TrimSuffix(host, ":80")
+	}
+	if scheme == "https" && strings.HasSuffix(host, ":443") {
+		return strings.TrimSuffix(host, ":443")
+	}
+
+	return host
+}
+
+func hashSHA256(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,170 @@
+package httpreqx
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings contains durations of the request phases collected via httptrace.
+// Phases that did not happen (e.g. DNS and Connect for a reused connection) are zero.
+// When redirects are followed, the phases of the last hop are reported, Total covers the whole exchange.
+type Timings struct {
+	// GetConnection is the time from the start of the request until a connection was obtained.
+	// It includes DNS, Connect and TLS for new connections and the time spent waiting for a free connection in the pool.
+	GetConnection time.Duration
+	DNS           time.Duration
+	Connect       time.Duration
+	TLS           time.Duration
+	// TimeToFirstByte is the time from the moment the request was fully written until the first byte of the response was received.
+	TimeToFirstByte time.Duration
+	// BodyTransfer is the time from the first response byte until the body was fully read or closed.
+	BodyTransfer time.Duration
+	Total        time.Duration
+	// ConnectionReused reports whether the connection was taken from the idle pool.
+	ConnectionReused bool
+}
+
+// connTrace collects timestamps of the request phases via httptrace.
+// When redirects are followed, the timestamps of the last hop are kept.
+type connTrace struct {
+	mu    sync.Mutex
+	times traceTimes
+}
+
+type traceTimes struct {
+	start         time.Time
+	dnsStart      time.Time
+	dnsDone       time.Time
+	connectStart  time.Time
+	connectDone   time.Time
+	tlsStart      time.Time
+	tlsDone       time.Time
+	gotConn       time.Time
+	wroteRequest  time.Time
+	firstByte     time.Time
+	bodyDone      time.Time
+	reused        bool
+	remoteAddress string
+}
+
+func newConnTrace() *connTrace {
+	return &connTrace{times: traceTimes{start: time.Now()}}
+}
+
+func (t *connTrace) set(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *connTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.times.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.set(&t.times.dnsDone) },
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			// Only the first dial is tracked when several addresses are tried.
+			if t.times.connectStart.IsZero() || !t.times.connectDone.IsZero() {
+				t.times.connectStart = time.Now()
+				t.times.connectDone = time.Time{}
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone:       func(_, _ string, _ error) { t.set(&t.times.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.times.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.times.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.times.gotConn = time.Now()
+			t.times.reused = info.Reused
+			if info.Conn != nil {
+				t.times.remoteAddress = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.times.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.times.firstByte) },
+	}
+}
+
+// traceRequest returns a shallow copy of the request with the trace attached to its context.
+func (t *connTrace) traceRequest(req *http.Request) *http.Request {
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace()))
+}
+
+// snapshot returns a copy of the collected timestamps, safe to read without locking.
+func (t *connTrace) snapshot() traceTimes {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.times
+}
+
+func (t traceTimes) timings() Timings {
+	end := t.bodyDone
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	return Timings{
+		GetConnection:    durationBetween(t.start, t.gotConn),
+		DNS:              durationBetween(t.dnsStart, t.dnsDone),
+		Connect:          durationBetween(t.connectStart, t.connectDone),
+		TLS:              durationBetween(t.tlsStart, t.tlsDone),
+		TimeToFirstByte:  durationBetween(t.wroteRequest, t.firstByte),
+		BodyTransfer:     durationBetween(t.firstByte, t.bodyDone),
+		Total:            durationBetween(t.start, end),
+		ConnectionReused: t.reused,
+	}
+}
+
+func durationBetween(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+
+	return to.Sub(from)
+}
+
+// tracedBody marks the end of the body transfer when the body is fully read or closed.
+// onRead is called with every chunk of the body, onDone is called exactly once.
+type tracedBody struct {
+	io.ReadCloser
+	trace  *connTrace
+	onRead func(p []byte)
+	onDone func(err error)
+	once   sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.onRead != nil {
+		b.onRead(p[:n])
+	}
+	if err != nil {
+		b.done(err)
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done(nil)
+	return err
+}
+
+func (b *tracedBody) done(err error) {
+	b.once.Do(func() {
+		b.trace.set(&b.trace.times.bodyDone)
+		if b.onDone != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			b.onDone(err)
+		}
+	})
+}
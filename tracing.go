@@ -0,0 +1,213 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TraceContext is the W3C Trace Context (see https://www.w3.org/TR/trace-context/) of the current operation.
+type TraceContext struct {
+	TraceParent string
+	TraceState  string
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of the context carrying the trace context.
+// The TraceParent must be valid according to the W3C Trace Context specification, otherwise it is ignored by the W3CTracer.
+func ContextWithTraceContext(ctx context.Context, traceContext TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext)
+}
+
+// TraceContextFromContext returns the trace context carried by the context, if any.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	traceContext, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return traceContext, ok
+}
+
+// ExtractTraceContext reads the traceparent and tracestate headers, e.g. of an incoming server request, and returns a copy of the context carrying them.
+// The context is returned unchanged if the traceparent header is missing or invalid.
+// Outgoing requests made with this context continue the same trace.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	traceParent := strings.TrimSpace(header.Get(HeaderTraceParent))
+	if _, _, _, ok := parseTraceParent(traceParent); !ok {
+		return ctx
+	}
+
+	return ContextWithTraceContext(ctx, TraceContext{
+		TraceParent: traceParent,
+		TraceState:  strings.Join(header.Values(HeaderTraceState), ","),
+	})
+}
+
+// SpanInfo describes the request attempt a span is started for.
+type SpanInfo struct {
+	Method string
+	// URLTemplate is the request URL before the path params are applied, masked with the Redactor of the request.
+	URLTemplate string
+	Host        string
+	// Route is the path of the URLTemplate, e.g. "/users/{id}".
+	Route string
+	// Attempt starts from 1 and is increased for every repeated attempt of the same request.
+	Attempt int
+}
+
+// SpanResult describes the outcome of the request attempt.
+type SpanResult struct {
+	// StatusCode is 0 if no response was received.
+	StatusCode int
+	Err        error
+	// ErrorPhase is empty if the attempt succeeded.
+	ErrorPhase ErrorPhase
+}
+
+// Span is a unit of work started by the Tracer for a request attempt.
+type Span interface {
+	End(result SpanResult)
+}
+
+// Tracer is the integration point for distributed tracing.
+// StartSpan is called for every attempt of Request.Do, the returned context is used for the http.Request.
+// Inject is called with the context returned by StartSpan to write the propagation headers into the outgoing request.
+//
+// An OpenTelemetry adapter fits in a few lines:
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, info httpreqx.SpanInfo) (context.Context, httpreqx.Span) {
+//		ctx, span := t.tracer.Start(ctx, "HTTP "+info.Method, trace.WithSpanKind(trace.SpanKindClient),
+//			trace.WithAttributes(attribute.String("http.request.method", info.Method), attribute.String("url.template", info.URLTemplate)))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	func (t otelTracer) Inject(ctx context.Context, header http.Header) {
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+//	}
+//
+//	func (s otelSpan) End(result httpreqx.SpanResult) {
+//		s.span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode))
+//		if result.Err != nil {
+//			s.span.RecordError(result.Err, trace.WithAttributes(attribute.String("error.phase", string(result.ErrorPhase))))
+//			s.span.SetStatus(codes.Error, result.Err.Error())
+//		}
+//		s.span.End()
+//	}
+type Tracer interface {
+	StartSpan(ctx context.Context, info SpanInfo) (context.Context, Span)
+	Inject(ctx context.Context, header http.Header)
+}
+
+// W3CTracer is a dependency-free Tracer that only propagates the W3C traceparent and tracestate headers.
+// If the request context carries a trace context (see ContextWithTraceContext and ExtractTraceContext),
+// it is forwarded unchanged, otherwise no headers are added.
+// It does not record or export spans, so it does not mint span ids either: downstream services would point to a parent that is never recorded.
+type W3CTracer struct{}
+
+// NewW3CTracer creates a W3CTracer. It is the default Tracer of the HttpClient.
+func NewW3CTracer() Tracer {
+	return &W3CTracer{}
+}
+
+func (t *W3CTracer) StartSpan(ctx context.Context, _ SpanInfo) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (t *W3CTracer) Inject(ctx context.Context, header http.Header) {
+	traceContext, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	if _, _, _, ok := parseTraceParent(traceContext.TraceParent); !ok {
+		return
+	}
+
+	header.Set(HeaderTraceParent, traceContext.TraceParent)
+	// A tracestate set by the caller is kept when the trace context has none.
+	if traceContext.TraceState != "" {
+		header.Set(HeaderTraceState, traceContext.TraceState)
+	}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(SpanResult) {}
+
+// parseTraceParent validates the traceparent header value, e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func parseTraceParent(traceParent string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+
+	version := parts[0]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", "", false
+	}
+
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", "", "", false
+	}
+	if !isLowerHex(parentID, 16) || parentID == strings.Repeat("0", 16) {
+		return "", "", "", false
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", "", false
+	}
+
+	return traceID, parentID, flags, true
+}
+
+func isLowerHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// startSpan starts the span for the attempt, it returns the original context and a nil span if tracing is disabled.
+func (r *Request) startSpan(attempt int) (context.Context, Span) {
+	tracer := r.client.tracer
+	if tracer == nil {
+		return r.ctx, nil
+	}
+
+	info := SpanInfo{
+		Method:      r.method,
+		URLTemplate: r.urlTemplate(),
+		Route:       r.routeTemplate(),
+		Attempt:     attempt,
+	}
+	if u, err := url.Parse(r.url()); err == nil {
+		info.Host = u.Host
+	}
+
+	return tracer.StartSpan(r.ctx, info)
+}
+
+func endSpan(span Span, resp *http.Response, err error) {
+	if span == nil {
+		return
+	}
+
+	result := SpanResult{Err: err}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+	}
+
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		result.ErrorPhase = requestErr.Phase
+	}
+
+	span.End(result)
+}
@@ -0,0 +1,124 @@
+package httpreqx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTracer struct {
+	mu      sync.Mutex
+	infos   []SpanInfo
+	results []SpanResult
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, info SpanInfo) (context.Context, Span) {
+	t.mu.Lock()
+	t.infos = append(t.infos, info)
+	t.mu.Unlock()
+	return ctx, t
+}
+
+func (t *recordingTracer) Inject(_ context.Context, header http.Header) {
+	header.Set("X-Test-Span", "1")
+}
+
+func (t *recordingTracer) End(result SpanResult) {
+	t.mu.Lock()
+	t.results = append(t.results, result)
+	t.mu.Unlock()
+}
+
+func TestTracing(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get(HeaderTraceParent) + "|" + r.Header.Get(HeaderTraceState) + "|" + r.Header.Get("X-Test-Span")))
+	}))
+	defer server.Close()
+
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("W3C propagation", func(t *testing.T) {
+		ctx := ExtractTraceContext(context.Background(), http.Header{
+			HeaderTraceParent: {traceParent},
+			HeaderTraceState:  {"vendor=value"},
+		})
+
+		var result string
+		_, err := NewHttpClient().NewGetRequest(ctx, server.URL).WriteBodyTo(&result).Do()
+		r.NoError(err)
+
+		// No span is recorded, so the incoming parent is forwarded unchanged
+		parts := strings.Split(result, "|")
+		r.Equal(traceParent, parts[0])
+		r.Equal("vendor=value", parts[1])
+	})
+
+	t.Run("Tracestate header of the caller is kept", func(t *testing.T) {
+		ctx := ContextWithTraceContext(context.Background(), TraceContext{TraceParent: traceParent})
+
+		var result string
+		_, err := NewHttpClient().NewGetRequest(ctx, server.URL).
+			SetHeader(HeaderTraceState, "caller=value").
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Equal(traceParent+"|caller=value|", result)
+	})
+
+	t.Run("No trace context", func(t *testing.T) {
+		var result string
+		_, err := NewHttpClient().NewGetRequest(context.Background(), server.URL).WriteBodyTo(&result).Do()
+		r.NoError(err)
+		r.Equal("||", result)
+
+		ctx := ExtractTraceContext(context.Background(), http.Header{HeaderTraceParent: {"00-invalid-00f067aa0ba902b7-01"}})
+		_, ok := TraceContextFromContext(ctx)
+		r.False(ok)
+	})
+
+	t.Run("Custom tracer", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		client := NewHttpClient().SetTracer(tracer)
+		ctx := context.Background()
+
+		var result string
+		_, err := client.NewGetRequest(ctx, server.URL+"/users/{id}?token=secret").
+			SetPathParam("id", "42").
+			WriteBodyTo(&result).
+			Do()
+		r.NoError(err)
+		r.Equal("||1", result)
+
+		_, err = client.NewGetRequest(ctx, server.URL+"/missing").Do()
+		r.Error(err)
+
+		var requestErr *RequestError
+		r.True(errors.As(err, &requestErr))
+		r.Equal(ErrorPhaseStatus, requestErr.Phase)
+		r.Equal(http.StatusNotFound, requestErr.StatusCode)
+
+		r.Len(tracer.infos, 2)
+		r.Equal(http.MethodGet, tracer.infos[0].Method)
+		r.Equal(server.URL+"/users/{id}?token=%5BREDACTED%5D", tracer.infos[0].URLTemplate)
+		r.Equal("/users/{id}", tracer.infos[0].Route)
+		r.Equal(1, tracer.infos[0].Attempt)
+
+		r.Len(tracer.results, 2)
+		r.Equal(SpanResult{StatusCode: http.StatusOK}, tracer.results[0])
+		r.Equal(http.StatusNotFound, tracer.results[1].StatusCode)
+		r.Equal(ErrorPhaseStatus, tracer.results[1].ErrorPhase)
+		r.Error(tracer.results[1].Err)
+	})
+}
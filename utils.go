@@ -6,6 +6,9 @@ import (
 	"net/http"
 )
 
+// maxDiscardedBodySize limits the number of bytes read from a discarded body, larger bodies are closed without reading them to the end.
+const maxDiscardedBodySize = 64 << 10
+
 func IsSuccessResponse(resp *http.Response) bool {
 	return resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300
 }
@@ -25,3 +28,13 @@ func CloneRequestBody(r *http.Request) ([]byte, error) {
 
 	return bodyBytes, nil
 }
+
+// discardBody drains and closes the body of a response that is not passed to the caller, so the connection can be reused.
+func discardBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscardedBodySize))
+	_ = resp.Body.Close()
+}
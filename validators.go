@@ -0,0 +1,99 @@
+package httpreqx
+
+import (
+	"net/http"
+	"time"
+)
+
+const validatorKeyPrefix = "validators "
+
+// ValidatorStore remembers the ETag and Last-Modified validators of GET responses per URL, along with their bodies,
+// and makes every later GET request to the URL conditional with the If-None-Match and If-Modified-Since headers.
+// A 304 Not Modified response is replaced with the stored response, so it is unmarshaled like the original one
+// instead of failing the status check. Unlike the HTTPCache, every request reaches the server, freshness is never assumed.
+// Requests with validators set by the caller are sent as they are, so the caller handles the 304 Not Modified response itself.
+// Responses with Cache-Control: no-store are not remembered. Replayed responses are marked like the responses of the HTTPCache, see IsCachedResponse.
+// As with the HTTPCache, stored responses are only used for requests with the same credentials.
+type ValidatorStore struct {
+	store        CacheStore
+	maxEntrySize int64
+}
+
+// NewValidatorStore creates a ValidatorStore backed by the store, see NewMemoryCacheStore and NewFileCacheStore.
+// Responses with bodies larger than 1 MiB are not remembered, see SetMaxEntrySize.
+func NewValidatorStore(store CacheStore) *ValidatorStore {
+	return &ValidatorStore{
+		store:        store,
+		maxEntrySize: defaultCacheMaxEntrySize,
+	}
+}
+
+// SetMaxEntrySize sets the maximum body size of a remembered response.
+func (v *ValidatorStore) SetMaxEntrySize(size int64) *ValidatorStore {
+	v.maxEntrySize = size
+	return v
+}
+
+func (v *ValidatorStore) roundTrip(req *http.Request, credentials string, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	key := cacheKey{url: validatorKeyPrefix + req.URL.String(), credentials: credentials}
+
+	if req.Method != http.MethodGet {
+		resp, err := send(req)
+		if err == nil && isUnsafeMethod(req.Method) && resp.StatusCode < 400 {
+			v.store.Delete(key.url)
+		}
+		return resp, err
+	}
+
+	// Range requests carry their own If-Range validator.
+	if req.Header.Get(HeaderRange) != "" {
+		return send(req)
+	}
+	if req.Header.Get(HeaderIfNoneMatch) != "" || req.Header.Get(HeaderIfModifiedSince) != "" {
+		return send(req)
+	}
+
+	entry := loadCacheEntry(v.store, key, req)
+
+	sent := req
+	if entry != nil {
+		if conditional := conditionalRequest(req, entry, req.Context()); conditional != nil {
+			sent = conditional
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := send(sent)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		discardBody(resp)
+		entry = entry.refreshed(resp, requestTime, time.Now())
+		saveCacheEntry(v.store, key, entry)
+
+		return entry.response(req, 0), nil
+	}
+
+	if IsSuccessResponse(resp) && v.remember(resp) {
+		if body, ok := readEntryBody(resp, v.maxEntrySize); ok {
+			saveCacheEntry(v.store, key, newCacheEntry(req, credentials, resp, body, requestTime, time.Now()))
+		}
+	}
+
+	return resp, nil
+}
+
+func (v *ValidatorStore) remember(resp *http.Response) bool {
+	if _, noStore := parseCacheControl(resp.Header)["no-store"]; noStore {
+		return false
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	return resp.Header.Get(HeaderETag) != "" || resp.Header.Get(HeaderLastModified) != ""
+}
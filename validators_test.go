@@ -0,0 +1,105 @@
+package httpreqx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorStore(t *testing.T) {
+	r := require.New(t)
+
+	var version, notModified int32 = 1, 0
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/items":
+			etag := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&version))
+			if req.Header.Get(HeaderIfNoneMatch) == etag {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set(HeaderETag, etag)
+		case "/modified":
+			if since, err := http.ParseTime(req.Header.Get(HeaderIfModifiedSince)); err == nil && !lastModified.After(since) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set(HeaderLastModified, lastModified.Format(http.TimeFormat))
+		}
+
+		w.Header().Set(HeaderContentType, "application/json")
+		fmt.Fprintf(w, `{"version":%d}`, atomic.LoadInt32(&version))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewHttpClient().
+		SetBodyUnmarshaler(NewJSONBodyUnmarshaler()).
+		SetValidatorStore(NewValidatorStore(NewMemoryCacheStore(1 << 20)))
+
+	type result struct {
+		Version int `json:"version"`
+	}
+
+	get := func(path string) (result, *http.Response) {
+		var res result
+		resp, err := client.NewGetRequest(ctx, server.URL+path).WriteBodyTo(&res).Do()
+		r.NoError(err)
+		return res, resp
+	}
+
+	t.Run("ETag", func(t *testing.T) {
+		res, resp := get("/items")
+		r.Equal(1, res.Version)
+		r.False(IsCachedResponse(resp))
+
+		res, resp = get("/items")
+		r.Equal(1, res.Version, "304 is replaced with the stored body")
+		r.True(IsCachedResponse(resp))
+		r.Equal(http.StatusOK, resp.StatusCode)
+		r.Equal(int32(1), atomic.LoadInt32(&notModified))
+
+		atomic.StoreInt32(&version, 2)
+		res, resp = get("/items")
+		r.Equal(2, res.Version)
+		r.False(IsCachedResponse(resp))
+
+		res, _ = get("/items")
+		r.Equal(2, res.Version)
+		r.Equal(int32(2), atomic.LoadInt32(&notModified))
+	})
+
+	t.Run("Last-Modified", func(t *testing.T) {
+		get("/modified")
+		res, resp := get("/modified")
+		r.True(IsCachedResponse(resp))
+		r.Equal(2, res.Version)
+	})
+
+	t.Run("Validators of the caller are kept", func(t *testing.T) {
+		get("/items")
+
+		resp, err := client.NewGetRequest(ctx, server.URL+"/items").
+			SetHeader(HeaderIfNoneMatch, fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&version))).
+			Do()
+		r.Error(err, "the 304 Not Modified response is left to the caller")
+		r.Equal(http.StatusNotModified, resp.StatusCode)
+		r.False(IsCachedResponse(resp))
+	})
+
+	t.Run("Not modified without stored response is an error", func(t *testing.T) {
+		_, err := NewHttpClient().NewGetRequest(ctx, server.URL+"/items").
+			SetHeader(HeaderIfNoneMatch, `"v2"`).
+			Do()
+		r.Error(err)
+	})
+}